@@ -2,34 +2,133 @@ package as3parsing
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/f5devcentral/f5-bigip-rest-go/utils"
 
 	f5_bigip "github.com/f5devcentral/f5-bigip-rest-go/bigip"
 )
 
+// ParseAS3 parses the as3 body with the parser set up by Initialize.
 func ParseAS3(ctx context.Context, as3obj map[string]interface{}) (map[string]interface{}, error) {
+	if defaultParser == nil {
+		return map[string]interface{}{}, fmt.Errorf("as3parsing is not initialized, call Initialize first")
+	}
+	return defaultParser.ParseAS3(ctx, as3obj)
+}
+
+// ParseAS3 parses the as3 body to the iControl REST objects, in format of:
+//
+//	partition -> folder -> "kind/name" -> body
+//
+// The problems found in the declaration are all returned together as Diagnostics.
+func (p *Parser) ParseAS3(ctx context.Context, as3obj map[string]interface{}) (map[string]interface{}, error) {
 	slog := utils.LogFromContext(ctx)
 	defer utils.TimeIt(slog)("ParseAS3 timecost")
 	defer utils.TimeItToPrometheus()()
 
+	return p.parseAS3(ctx, as3obj, nil)
+}
+
+// ParseAS3Tenants parses only the named tenants of the as3 body, like AS3's /declare/<tenant>.
+// The other tenants are left out, except /Common, which is kept for resolving the references to /Common/Shared.
+func (p *Parser) ParseAS3Tenants(ctx context.Context, as3obj map[string]interface{}, tenants ...string) (map[string]interface{}, error) {
+	slog := utils.LogFromContext(ctx)
+	defer utils.TimeIt(slog)("ParseAS3Tenants timecost")
+	defer utils.TimeItToPrometheus()()
+
+	if len(tenants) == 0 {
+		return map[string]interface{}{}, fmt.Errorf("no tenant given")
+	}
+	return p.parseAS3(ctx, as3obj, tenants)
+}
+
+// TenantResult is the parsing result of one tenant. RestObjs holds the partitions generated for
+// the tenant, i.e. the tenant's own, and /Common for the shared nodes.
+type TenantResult struct {
+	RestObjs map[string]interface{}
+	Err      error
+}
+
+// ParseAS3PerTenant parses the named tenants, all of the declaration if none, each independently,
+// so that the problems of one tenant don't block the others. The error returned is for the as3 body as a whole.
+func (p *Parser) ParseAS3PerTenant(ctx context.Context, as3obj map[string]interface{}, tenants ...string) (map[string]*TenantResult, error) {
+	slog := utils.LogFromContext(ctx)
+	defer utils.TimeIt(slog)("ParseAS3PerTenant timecost")
+	defer utils.TimeItToPrometheus()()
+
+	results := map[string]*TenantResult{}
+	if _, f := as3obj["declaration"]; !f {
+		return results, fmt.Errorf("no declaration found in the given as3 body")
+	}
+	declaration, ok := as3obj["declaration"].(map[string]interface{})
+	if !ok {
+		return results, malformedDeclaration()
+	}
+	if len(tenants) == 0 {
+		for k, v := range declaration {
+			if obj, ok := v.(map[string]interface{}); ok && obj["class"] == "Tenant" {
+				tenants = append(tenants, k)
+			}
+		}
+	}
+	for _, t := range tenants {
+		restobjs, err := p.parseAS3(ctx, as3obj, []string{t})
+		results[t] = &TenantResult{RestObjs: restobjs, Err: err}
+	}
+	return results, nil
+}
+
+// parseAS3 parses the tenants of the as3 body, all if tenants is nil.
+// Only when parsing all, the declaration with defaults is set back to as3obj.
+func (p *Parser) parseAS3(ctx context.Context, as3obj map[string]interface{}, tenants []string) (map[string]interface{}, error) {
 	restobjs := map[string]interface{}{}
 	if _, f := as3obj["declaration"]; !f {
 		return restobjs, fmt.Errorf("no declaration found in the given as3 body")
 	}
-	declaration, err := utils.DeepCopy(as3obj["declaration"])
+	if err := p.ensureAs3Service(ctx); err != nil {
+		return restobjs, err
+	}
+	copiedDecl, err := utils.DeepCopy(as3obj["declaration"])
 	if err != nil {
 		return restobjs, err
 	}
-	if decl, err := addDefaults(ctx, declaration.(map[string]interface{})); err != nil {
+	declaration, ok := copiedDecl.(map[string]interface{})
+	if !ok {
+		return restobjs, malformedDeclaration()
+	}
+	if tenants != nil {
+		if declaration, err = filterTenants(declaration, tenants); err != nil {
+			return restobjs, err
+		}
+		copied := map[string]interface{}{}
+		for k, v := range as3obj {
+			copied[k] = v
+		}
+		as3obj = copied
+	}
+	ds := Diagnostics{}
+	if decl, err := p.addDefaults(ctx, declaration); err != nil {
 		return restobjs, err
 	} else {
+		ds.add("", resolveReferences(decl))
 		as3obj["declaration"] = decl
 	}
-	restobjs, err = parseToRest(ctx, as3obj)
-	if err != nil {
+	restobjs, err = p.parseToRest(ctx, as3obj, tenants)
+	if ctx.Err() != nil {
+		return restobjs, ctx.Err()
+	}
+	ds.add("", err)
+	// Common is parsed only for the references to it.
+	if tenants != nil && !utils.Contains(tenants, "Common") {
+		ds = ds.without("Common")
+	}
+	if err := ds.err(); err != nil {
 		return restobjs, err
 	}
 
@@ -37,30 +136,73 @@ func ParseAS3(ctx context.Context, as3obj map[string]interface{}) (map[string]in
 	return restobjs, err
 }
 
-func parseToRest(ctx context.Context, as3obj map[string]interface{}) (map[string]interface{}, error) {
+// malformedDeclaration is the error of the declaration not being an object.
+func malformedDeclaration() error {
+	ds := Diagnostics{}
+	ds.add("declaration", newDiagError(CodeMalformed, "declaration should be an object"))
+	return ds.err()
+}
+
+// filterTenants returns the declaration with only the named tenants, and the Common tenant for references.
+func filterTenants(declaration map[string]interface{}, tenants []string) (map[string]interface{}, error) {
+	filtered := map[string]interface{}{}
+	for k, v := range declaration {
+		if obj, ok := v.(map[string]interface{}); !ok || obj["class"] != "Tenant" || k == "Common" {
+			filtered[k] = v
+		}
+	}
+	for _, t := range tenants {
+		obj, ok := declaration[t].(map[string]interface{})
+		if !ok || obj["class"] != "Tenant" {
+			return nil, fmt.Errorf("tenant %s not found in the declaration", t)
+		}
+		filtered[t] = obj
+	}
+	return filtered, nil
+}
+
+// parseToRest converts the tenants of as3obj to REST objects, all if tenants is nil.
+// The other tenants are still parsed for looking up the objects referred.
+func (p *Parser) parseToRest(ctx context.Context, as3obj map[string]interface{}, tenants []string) (map[string]interface{}, error) {
 	defer utils.TimeItToPrometheus()()
 	objs1 := map[string]interface{}{}
 	objs2 := map[string]interface{}{}
 	slog := utils.LogFromContext(ctx)
-	pc, cc := newParseContext(ctx), newConvertContext(ctx)
+	pc, cc := newParseContext(ctx, p), newConvertContext(ctx, p)
+	// go on converting when parsing fails, to find all the problems in one pass.
+	ds := Diagnostics{}
 	if err := pc.parse(as3obj, objs1); err != nil {
-		return objs2, err
+		ds.add("", err)
 	} else {
 		bobjs, _ := utils.MarshalNoEscaping(objs1)
 		slog.Debugf("parsed as3body: %s", bobjs)
 	}
+	cc.parsed = objs1
+	if declaration, ok := as3obj["declaration"].(map[string]interface{}); ok {
+		rds, err := tenantRouteDomains(declaration)
+		ds.add("", err)
+		cc.routeDomains = rds
+	}
+	if tenants != nil {
+		objs1 = map[string]interface{}{}
+		for _, t := range tenants {
+			if obj, f := cc.parsed[t]; f {
+				objs1[t] = obj
+			}
+		}
+	}
 
 	if err := cc.convert("", objs1, objs2); err != nil {
-		return objs2, err
+		ds.add("", err)
 	} else {
 		bobjs, _ := utils.MarshalNoEscaping(objs2)
 		slog.Debugf("converted as3body: %s", bobjs)
 	}
 
-	return objs2, nil
+	return objs2, ds.err()
 }
 
-func loadProperties() error {
+func loadProperties() (map[string]Properties, error) {
 	// fp := os.Args[0]
 	// propPath := strings.Join([]string{path.Dir(fp), "rest.properties.json"}, "/")
 	// if _, err := os.Stat(propPath); err != nil {
@@ -75,19 +217,256 @@ func loadProperties() error {
 	// 	return err
 	// }
 
+	var properties map[string]Properties
 	bProps, err := propFile.ReadFile("rest.properties.json")
 	if err != nil {
-		return fmt.Errorf("failed to open rest.properties.json: %s", err.Error())
+		return nil, fmt.Errorf("failed to open rest.properties.json: %s", err.Error())
 	}
 	if err := json.Unmarshal(bProps, &properties); err != nil {
-		return fmt.Errorf("failed to unmarshal properties data: %s", err)
+		return nil, fmt.Errorf("failed to unmarshal properties data: %s", err)
+	}
+	return properties, nil
+}
+
+func loadSchemaDefaults() (SchemaDefaults, error) {
+	var defaults SchemaDefaults
+	bDefaults, err := defaultsFile.ReadFile("as3.defaults.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open as3.defaults.json: %s", err.Error())
+	}
+	if err := json.Unmarshal(bDefaults, &defaults); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema defaults data: %s", err)
+	}
+	return defaults, nil
+}
+
+// WithBIGIP sets the BIG-IP used to add defaults in DefaultsModeBigip,
+// its version is taken unless WithBIGIPVersion is given.
+func WithBIGIP(bip *f5_bigip.BIGIP) ParserOption {
+	return func(p *Parser) {
+		p.bigip = bip
+	}
+}
+
+// WithAS3Service sets the as3 service url used to add defaults in DefaultsModeLocal or DefaultsModeBigip.
+func WithAS3Service(as3Svc string) ParserOption {
+	return func(p *Parser) {
+		p.as3Service = as3Svc
+	}
+}
+
+// WithDefaultsMode sets the defaults source explicitly: DefaultsModeSchema, DefaultsModeLocal or DefaultsModeBigip.
+func WithDefaultsMode(mode string) ParserOption {
+	return func(p *Parser) {
+		p.defaultsMode = mode
 	}
-	return nil
 }
 
+// WithProperties replaces the embedded rest.properties.json.
+func WithProperties(props map[string]Properties) ParserOption {
+	return func(p *Parser) {
+		p.properties = props
+	}
+}
+
+// WithBIGIPVersion sets the BIG-IP version the REST objects are generated for, i.e. "15.1.0".
+func WithBIGIPVersion(version string) ParserOption {
+	return func(p *Parser) {
+		p.version = version
+	}
+}
+
+// WithReadinessCheck sets when to check the as3 service is ready, and how to retry it:
+//
+//	ReadinessLazy -> check it on the first ParseAS3 call, until it succeeds once (default)
+//	ReadinessSkip -> never check it
+//
+// It takes no effect in DefaultsModeSchema.
+func WithReadinessCheck(readiness string, policy RetryPolicy) ParserOption {
+	return func(p *Parser) {
+		p.readiness = readiness
+		p.retryPolicy = policy
+	}
+}
+
+// WithConcurrency sets how many as3 applications are converted at the same time, 1 by default.
+// Only the converting is concurrent, the declaration is still parsed, which only regroups the objects, in one goroutine.
+func WithConcurrency(n int) ParserOption {
+	return func(p *Parser) {
+		p.concurrency = n
+	}
+}
+
+// NewParser creates a Parser. Without options, it adds defaults from the embedded schema,
+// and generates REST objects with the embedded rest.properties.json.
+//
+// If no defaults mode is given, it's decided by the as3 service:
+//
+//	""                    -> DefaultsModeSchema
+//	prefixed by BIG-IP URL -> DefaultsModeBigip
+//	others                -> DefaultsModeLocal
+func NewParser(opts ...ParserOption) (*Parser, error) {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.defaultsMode == "" {
+		if p.as3Service == "" {
+			p.defaultsMode = DefaultsModeSchema
+		} else if p.bigip != nil && strings.HasPrefix(p.as3Service, p.bigip.URL) {
+			p.defaultsMode = DefaultsModeBigip
+		} else {
+			p.defaultsMode = DefaultsModeLocal
+		}
+	}
+	switch p.defaultsMode {
+	case DefaultsModeSchema:
+	case DefaultsModeLocal:
+		if p.as3Service == "" {
+			return nil, fmt.Errorf("as3 service is required for defaults mode %s", p.defaultsMode)
+		}
+	case DefaultsModeBigip:
+		if p.bigip == nil {
+			return nil, fmt.Errorf("BIG-IP is required for defaults mode %s", p.defaultsMode)
+		}
+		if p.as3Service == "" {
+			p.as3Service = p.bigip.URL
+		}
+	default:
+		return nil, fmt.Errorf("unknown defaults mode: %s", p.defaultsMode)
+	}
+
+	switch p.readiness {
+	case "":
+		p.readiness = ReadinessLazy
+		p.retryPolicy = DefaultRetryPolicy
+	case ReadinessLazy, ReadinessSkip:
+	default:
+		return nil, fmt.Errorf("unknown readiness check: %s", p.readiness)
+	}
+
+	if p.concurrency < 1 {
+		p.concurrency = 1
+	}
+
+	if p.discoverers == nil {
+		p.discoverers = map[string]MemberDiscoverer{}
+	}
+	if _, f := p.discoverers["consul"]; !f {
+		p.discoverers["consul"] = NewConsulDiscoverer()
+	}
+
+	if p.version == "" && p.bigip != nil {
+		p.version = p.bigip.Version
+	}
+
+	if p.properties == nil {
+		props, err := loadProperties()
+		if err != nil {
+			return nil, err
+		}
+		p.properties = props
+	}
+	defaults, err := loadSchemaDefaults()
+	if err != nil {
+		return nil, err
+	}
+	p.defaults = defaults
+
+	return p, nil
+}
+
+// Initialize sets up the default parser used by ParseAS3.
+// as3Svc decides the defaults mode:
+//
+//	""                  -> DefaultsModeSchema, add defaults offline from the embedded schema
+//	prefixed by bip.URL -> DefaultsModeBigip, dry-run the declaration on BIG-IP
+//	others              -> DefaultsModeLocal, validate the declaration via the as3 service
+//
+// It doesn't wait for the as3 service, which is checked on the first ParseAS3 call, see ReadinessLazy.
 func Initialize(bip *f5_bigip.BIGIP, as3Svc string, logLevel string) error {
-	as3Service = as3Svc
-	bigip = bip
-	waitForAs3Service()
-	return loadProperties()
+	p, err := NewParser(WithBIGIP(bip), WithAS3Service(as3Svc))
+	if err != nil {
+		return err
+	}
+	defaultParser = p
+	return nil
+}
+
+// WaitForAS3Service checks the as3 service used to add defaults is ready, retrying it with policy.
+// It returns an error if the service is still not ready after all retries or ctx is done.
+func (p *Parser) WaitForAS3Service(ctx context.Context, policy RetryPolicy) error {
+	if p.defaultsMode == DefaultsModeSchema {
+		return nil
+	}
+	slog := utils.LogFromContext(ctx)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+	as3ep := fmt.Sprintf("%s/any", p.as3Service)
+	headers := map[string]string{}
+	if p.defaultsMode == DefaultsModeBigip {
+		as3ep = fmt.Sprintf("%s/mgmt/shared/appsvcs/info", p.bigip.URL)
+	}
+	if p.bigip != nil {
+		headers["Authorization"] = p.bigip.Authorization
+	}
+	tryGet := func() error {
+		if status, response, err := utils.HttpRequest(
+			client, as3ep, "GET", "", headers); err != nil {
+			return err
+		} else if status == 200 {
+			return nil
+		} else {
+			return fmt.Errorf("as3 parser service response with code %d, response: %s", status, response)
+		}
+	}
+
+	times := policy.Times
+	if times < 1 {
+		times = 1
+	}
+	var err error
+	for i := 0; i < times; i++ {
+		if err = tryGet(); err == nil {
+			return nil
+		}
+		slog.Warnf("%s, retries left %d", err.Error(), times-i-1)
+		if i == times-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("as3 parser service is not available: %s", ctx.Err())
+		case <-time.After(policy.Interval):
+		}
+	}
+	return fmt.Errorf("as3 parser service is not available: %s", err.Error())
+}
+
+// ensureAs3Service checks the as3 service readiness once, according to the parser's readiness check.
+// The callers before it's ready wait for it each with their own ctx, the mutex is not held while waiting.
+func (p *Parser) ensureAs3Service(ctx context.Context) error {
+	if p.readiness == ReadinessSkip {
+		return nil
+	}
+	p.readyMutex.Lock()
+	ready := p.ready
+	p.readyMutex.Unlock()
+	if ready {
+		return nil
+	}
+	if err := p.WaitForAS3Service(ctx, p.retryPolicy); err != nil {
+		return err
+	}
+	p.readyMutex.Lock()
+	p.ready = true
+	p.readyMutex.Unlock()
+	return nil
 }
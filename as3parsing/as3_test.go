@@ -0,0 +1,148 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnsureAs3Service(t *testing.T) {
+	var up atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewParser(WithAS3Service(srv.URL), WithReadinessCheck(ReadinessLazy, RetryPolicy{Times: 1000, Interval: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one caller keeps waiting for the service.
+	waiting, stop := context.WithCancel(context.TODO())
+	defer stop()
+	done := make(chan error)
+	go func() { done <- p.ensureAs3Service(waiting) }()
+	time.Sleep(50 * time.Millisecond)
+
+	// another one is not blocked by it.
+	cancelled, cancel := context.WithCancel(context.TODO())
+	cancel()
+	start := time.Now()
+	if err := p.ensureAs3Service(cancelled); err == nil {
+		t.Error("got ready while the service is down")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("blocked by the waiting caller for %s", d)
+	}
+
+	up.Store(true)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %s after the service is up", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting after the service is up")
+	}
+	// ready once, not checked any more.
+	up.Store(false)
+	if err := p.ensureAs3Service(cancelled); err != nil {
+		t.Errorf("got error %s after ready", err)
+	}
+}
+
+func TestInitializeNotWaiting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	defer func(p *Parser) { defaultParser = p }(defaultParser)
+
+	start := time.Now()
+	if err := Initialize(nil, srv.URL, "info"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Initialize waited %s for the as3 service", d)
+	}
+}
+
+func TestParseAS3PerTenant(t *testing.T) {
+	body := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{"class": "AS3", "declaration": {"class": "ADC", "schemaVersion": "3.0.0",
+		"Common": {"class": "Tenant", "Shared": {"class": "Application", "template": "shared",
+			"cp": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"]}]},
+			"broken": {"class": "Pool", "minimumMonitors": "some"}}},
+		"T1": {"class": "Tenant", "A": {"class": "Application",
+			"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80, "snat": "any", "pool": "nope"}}},
+		"T2": {"class": "Tenant", "A": {"class": "Application",
+			"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.2"], "virtualPort": 80, "pool": "cp"}}}}}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := p.ParseAS3PerTenant(context.TODO(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tenant      string
+		partitions  []string
+		diagnostics []string
+	}{
+		{"Common", []string{"Common"}, []string{"/Common/Shared/broken/minimumMonitors invalid-value"}},
+		{"T1", nil, []string{"/T1/A/vs/pool dangling-reference", "/T1/A/vs/snat invalid-value"}},
+		{"T2", []string{"T2"}, nil},
+	}
+	if len(results) != len(cases) {
+		t.Errorf("got results of %d tenants, want %d", len(results), len(cases))
+	}
+	for _, c := range cases {
+		r, f := results[c.tenant]
+		if !f {
+			t.Errorf("%s: no result", c.tenant)
+			continue
+		}
+		if got, want := diagnostics(r.Err), strings.Join(c.diagnostics, "\n"); got != want {
+			t.Errorf("%s: got diagnostics:\n%s\nwant:\n%s", c.tenant, got, want)
+		}
+		if r.Err != nil {
+			continue
+		}
+		partitions := []string{}
+		for pn := range r.RestObjs {
+			partitions = append(partitions, pn)
+		}
+		sort.Strings(partitions)
+		if !reflect.DeepEqual(partitions, c.partitions) {
+			t.Errorf("%s: got partitions %v, want %v", c.tenant, partitions, c.partitions)
+		}
+	}
+
+	// the filter of ParseAS3Tenants, Common is parsed for the references only.
+	restobjs, err := p.ParseAS3Tenants(context.TODO(), body, "T2")
+	if err != nil {
+		t.Fatalf("T2: got error %s", err)
+	}
+	if _, f := restobjs["T1"]; f {
+		t.Errorf("T2: got T1 partition")
+	}
+	_, err = p.ParseAS3Tenants(context.TODO(), body, "T1", "T2")
+	want := []string{"/T1/A/vs/pool dangling-reference", "/T1/A/vs/snat invalid-value"}
+	if got := diagnostics(err); got != strings.Join(want, "\n") {
+		t.Errorf("T1, T2: got diagnostics:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
@@ -4,56 +4,125 @@ import (
 	"encoding/base64"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/f5devcentral/f5-bigip-rest-go/utils"
 )
 
+// convert converts all the parsed objects in objsrc, parent is the folder path, i.e. /Sample_02/A1.
+// The problems of all the objects are returned together as Diagnostics.
 func (cc *ConvertContext) convert(parent string, objsrc map[string]interface{}, objdst map[string]interface{}) error {
-	var err error = nil
+	// the caller takes the context error.
+	if cc.Err() != nil {
+		return nil
+	}
+	if parent == "" && cc.concurrency > 1 {
+		return cc.convertConcurrently(objsrc, objdst)
+	}
+	ds := Diagnostics{}
 	for k, v := range objsrc {
 		tn := strings.Split(k, "/")
 		if len(tn) > 2 {
-			l := len(tn)
-			// t := strings.Join(tn[0:l-1], "/")
-			n := tn[l-1]
-			switch strings.Join(tn[0:2], "/") {
-			case "ltm/virtual":
-				err = cc.convertVirtual(parent, n, v.(map[string]interface{}), objsrc, objdst)
-			case "ltm/pool":
-				err = cc.convertPool(n, v.(map[string]interface{}), objdst)
-			case "ltm/profile":
-				err = cc.convertProfile(parent, tn[2], n, v.(map[string]interface{}), objsrc, objdst)
-			case "ltm/monitor":
-				err = cc.convertMonitor(k, n, v.(map[string]interface{}), objdst)
-			case "ltm/persistence":
-				err = cc.convertPersist(k, n, v.(map[string]interface{}), objdst)
-			case "ltm/rule":
-				err = cc.convertiRule(n, v.(map[string]interface{}), objdst)
-			case "ltm/snatpool":
-				err = cc.convertSnatpool(n, v.(map[string]interface{}), objdst)
-			case "ltm/virtual-address":
-				err = cc.convertVirtualAddress(n, v.(map[string]interface{}), objdst)
-			case "fake_api/certificate":
-			case "fake_api/ca_bundle":
-			case "shared/file-transfer":
-				kind := strings.Join(tn[0:l-1], "/")
-				err = cc.convertSharedFileTransfer(parent, kind, n, v.(map[string]interface{}), objdst)
-			case "sys/file":
-				kind := strings.Join(tn[0:l-1], "/")
-				err = cc.convertSysCertificate(parent, kind, n, v.(map[string]interface{}), objdst)
-			default:
-				err = fmt.Errorf("found unknown key type: %s name: '%s'", strings.Join(tn[0:2], "/"), k)
-			}
+			ds.add(tn[len(tn)-1], cc.convertItem(parent, k, v, objsrc, objdst))
 		} else {
 			objdst[k] = map[string]interface{}{}
-			err = cc.convert(parent+"/"+k, v.(map[string]interface{}), objdst[k].(map[string]interface{}))
+			ds.add(k, cc.convert(parent+"/"+k, v.(map[string]interface{}), objdst[k].(map[string]interface{})))
 		}
-		if err != nil {
-			return err
+	}
+	return ds.err()
+}
+
+// convertConcurrently converts the partitions in objsrc with at most cc.concurrency workers.
+// Each folder, i.e. as3 application, is converted by one worker the same way as the sequential path.
+func (cc *ConvertContext) convertConcurrently(objsrc map[string]interface{}, objdst map[string]interface{}) error {
+	type job struct {
+		partition, folder string
+		src, dst          map[string]interface{}
+	}
+	ds := Diagnostics{}
+	jobs := []job{}
+	for pn, pv := range objsrc {
+		pdst := map[string]interface{}{}
+		objdst[pn] = pdst
+		psrc := pv.(map[string]interface{})
+		for fn, fv := range psrc {
+			if tn := strings.Split(fn, "/"); len(tn) > 2 {
+				ds.add(pn+"/"+tn[len(tn)-1], cc.convertItem("/"+pn, fn, fv, psrc, pdst))
+				continue
+			}
+			pdst[fn] = map[string]interface{}{}
+			jobs = append(jobs, job{pn, fn, fv.(map[string]interface{}), pdst[fn].(map[string]interface{})})
 		}
 	}
-	return nil
+
+	errs := make([]error, len(jobs))
+	workers := make(chan struct{}, cc.concurrency)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		// no more jobs once cancelled, the caller takes the context error.
+		if cc.Err() != nil {
+			break
+		}
+		select {
+		case <-cc.Done():
+		case workers <- struct{}{}:
+			wg.Add(1)
+			go func(i int, j job) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				errs[i] = cc.convert("/"+j.partition+"/"+j.folder, j.src, j.dst)
+			}(i, j)
+		}
+	}
+	wg.Wait()
+	for i, j := range jobs {
+		ds.add(j.partition+"/"+j.folder, errs[i])
+	}
+	return ds.err()
+}
+
+// convertItem converts the parsed object of key "kind/name", i.e. "ltm/pool/web_pool".
+func (cc *ConvertContext) convertItem(parent, k string, v interface{}, objsrc, objdst map[string]interface{}) (err error) {
+	defer malformed(&err)
+	tn := strings.Split(k, "/")
+	l := len(tn)
+	n := tn[l-1]
+	switch strings.Join(tn[0:2], "/") {
+	case "ltm/virtual":
+		err = cc.convertVirtual(parent, n, v.(map[string]interface{}), objsrc, objdst)
+	case "ltm/pool":
+		err = cc.convertPool(parent, n, v.(map[string]interface{}), objdst)
+	case "ltm/profile":
+		err = cc.convertProfile(parent, tn[2], n, v.(map[string]interface{}), objsrc, objdst)
+	case "ltm/monitor":
+		err = cc.convertMonitor(parent, k, n, v.(map[string]interface{}), objsrc, objdst)
+	case "ltm/persistence":
+		err = cc.convertPersist(k, n, v.(map[string]interface{}), objdst)
+	case "ltm/rule":
+		err = cc.convertiRule(n, v.(map[string]interface{}), objdst)
+	case "ltm/snatpool":
+		err = cc.convertSnatpool(parent, n, v.(map[string]interface{}), objdst)
+	case "ltm/virtual-address":
+		err = cc.convertVirtualAddress(parent, n, v.(map[string]interface{}), objdst)
+	case "ltm/policy":
+		err = cc.convertPolicy(n, v.(map[string]interface{}), objdst)
+	case "ltm/data-group":
+		err = cc.convertDataGroup(parent, tn[2], n, v.(map[string]interface{}), objdst)
+	case "fake_api/certificate":
+	case "fake_api/ca_bundle":
+	case "shared/file-transfer":
+		kind := strings.Join(tn[0:l-1], "/")
+		err = cc.convertSharedFileTransfer(parent, kind, n, v.(map[string]interface{}), objdst)
+	case "sys/file":
+		kind := strings.Join(tn[0:l-1], "/")
+		err = cc.convertSysCertificate(parent, kind, n, v.(map[string]interface{}), objdst)
+	default:
+		err = fmt.Errorf("found unknown key type: %s name: '%s'", strings.Join(tn[0:2], "/"), k)
+	}
+	return err
 }
 
 func (cc *ConvertContext) convertSharedFileTransfer(parent, kind, name string, obj, objdst map[string]interface{}) error {
@@ -91,25 +160,28 @@ func (cc *ConvertContext) convertSysCertificate(parent, kind, name string, obj,
 	return nil
 }
 
-func (cc *ConvertContext) convertVirtualAddress(name string, obj, objdst map[string]interface{}) error {
+func (cc *ConvertContext) convertVirtualAddress(parent, name string, obj, objdst map[string]interface{}) error {
 	virtualAddress := map[string]interface{}{}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "icmpEcho":
 			// f5-appsvcs: behaviors from f5-appsvcs which is chibaolechengde
 			// itemCopy.icmpEcho = itemCopy.icmpEcho.replace(/able$/, 'abled');
-			virtualAddress[restname("ltm/virtual-address", k)] = strings.ReplaceAll(v.(string), "able", "abled")
+			virtualAddress[cc.restname("ltm/virtual-address", k)] = strings.ReplaceAll(v.(string), "able", "abled")
 		case "routeAdvertisement":
-			virtualAddress[restname("ltm/virtual-address", k)] = strings.ReplaceAll(v.(string), "able", "abled")
+			virtualAddress[cc.restname("ltm/virtual-address", k)] = strings.ReplaceAll(v.(string), "able", "abled")
 		default:
 			dt, err := cc.convertByType("ltm/virtual-address", k, v)
 			if err != nil {
 				return err
 			}
-			virtualAddress[restname("ltm/virtual-address", k)] = dt
+			virtualAddress[cc.restname("ltm/virtual-address", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	// use address as the virtual-address name.
@@ -119,26 +191,115 @@ func (cc *ConvertContext) convertVirtualAddress(name string, obj, objdst map[str
 	if ipaddr, f := virtualAddress["address"]; !f {
 		return fmt.Errorf("virtual-address 'address' field not found")
 	} else {
-		virtualAddress["name"] = ipaddr
-		objdst["ltm/virtual-address/"+ipaddr.(string)] = virtualAddress
+		addr, mask, err := splitAddress(ipaddr.(string))
+		if err != nil {
+			return err
+		}
+		addr = rdAddress(addr, cc.routeDomain(parent))
+		if _, f := virtualAddress["mask"]; !f && mask != "" {
+			virtualAddress["mask"] = mask
+		}
+		virtualAddress["name"] = addr
+		virtualAddress["address"] = addr
+		objdst["ltm/virtual-address/"+addr] = virtualAddress
+		return nil
+	}
+}
+
+func (cc *ConvertContext) convertDataGroup(parent, kind, name string, obj, objdst map[string]interface{}) error {
+	datagroup := map[string]interface{}{
+		"name": name,
+	}
+	if err := eachProperty(obj, func(k string, v interface{}) error {
+		switch k {
+		case "class":
+		case "storageType":
+		case "externalFilePath":
+		case "separator":
+		case "ignoreChecksum":
+		case "records":
+			if kind == "external" {
+				// records are uploaded as the data group file.
+				return nil
+			}
+			items, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("data group records should be an array")
+			}
+			records := []interface{}{}
+			for _, item := range items {
+				record, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("data group record should be an object: %v", item)
+				}
+				key, f := record["key"]
+				if !f {
+					return fmt.Errorf("data group record requires key: %v", item)
+				}
+				r := map[string]interface{}{
+					"name": recordString(key),
+				}
+				if value, f := record["value"]; f {
+					r["data"] = recordString(value)
+				}
+				records = append(records, r)
+			}
+			datagroup[cc.restname("ltm/data-group/"+kind, k)] = records
+		default:
+			dt, err := cc.convertByType("ltm/data-group/"+kind, k, v)
+			if err != nil {
+				return err
+			}
+			datagroup[cc.restname("ltm/data-group/"+kind, k)] = dt
+		}
 		return nil
+	}); err != nil {
+		return err
 	}
+	if kind == "external" {
+		// the data group file is created in the same folder, see parseDataGroup
+		datagroup["externalFileName"] = parent + "/" + name
+	}
+
+	objdst["ltm/data-group/"+kind+"/"+name] = datagroup
+	return nil
 }
 
-func (cc *ConvertContext) convertSnatpool(name string, obj, objdst map[string]interface{}) error {
+func (cc *ConvertContext) convertSnatpool(parent, name string, obj, objdst map[string]interface{}) error {
 	snatpool := map[string]interface{}{
 		"name": name,
 	}
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
+		case "snatAddresses":
+			items, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("snatAddresses should be an array")
+			}
+			members := []interface{}{}
+			for _, item := range items {
+				addr, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("snatAddresses item should be string: %v", item)
+				}
+				addr, err := cc.tenantAddress(parent, addr)
+				if err != nil {
+					return err
+				}
+				members = append(members, addr)
+			}
+			snatpool[cc.restname("ltm/snatpool", k)] = members
 		default:
 			dt, err := cc.convertByType("ltm/snatpool", k, v)
 			if err != nil {
 				return err
 			}
-			snatpool[restname("ltm/snatpool", k)] = dt
+			snatpool[cc.restname("ltm/snatpool", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	objdst["ltm/snatpool/"+name] = snatpool
 	return nil
@@ -148,7 +309,7 @@ func (cc *ConvertContext) convertiRule(name string, obj, objdst map[string]inter
 	irule := map[string]interface{}{
 		"name": name,
 	}
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		default:
@@ -156,8 +317,11 @@ func (cc *ConvertContext) convertiRule(name string, obj, objdst map[string]inter
 			if err != nil {
 				return err
 			}
-			irule[restname("ltm/rule", k)] = dt
+			irule[cc.restname("ltm/rule", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst["ltm/rule/"+name] = irule
@@ -168,29 +332,32 @@ func (cc *ConvertContext) convertPersist(kn, name string, obj, objdst map[string
 	persist := map[string]interface{}{
 		"name": name,
 	}
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "persistenceMethod":
 		case "duration":
 			if i, ok := v.(float64); ok && i == 0 {
-				persist[restname("ltm/persistence", k)] = "indefinite"
+				persist[cc.restname("ltm/persistence", k)] = "indefinite"
 			} else {
-				persist[restname("ltm/persistence", k)] = v
+				persist[cc.restname("ltm/persistence", k)] = v
 			}
 		case "passphrase":
 			pass, err := cc.convertSecret(v)
 			if err != nil {
 				return err
 			}
-			persist[restname("ltm/persistence", k)] = pass
+			persist[cc.restname("ltm/persistence", k)] = pass
 		default:
 			dt, err := cc.convertByType("ltm/persistence", k, v)
 			if err != nil {
 				return err
 			}
-			persist[restname("ltm/persistence", k)] = dt
+			persist[cc.restname("ltm/persistence", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst[kn] = persist
@@ -225,7 +392,7 @@ func (cc *ConvertContext) convertSecret(obj interface{}) (string, error) {
 }
 
 func (cc *ConvertContext) convertBool(kind, as3name string, value bool) interface{} {
-	if k, f := properties[kind]; f {
+	if k, f := cc.properties[kind]; f {
 		if n, f := k[as3name]; f {
 			if n.Truth != "" && value {
 				return n.Truth
@@ -259,10 +426,10 @@ func (cc *ConvertContext) convertF5string(obj interface{}) (interface{}, error)
 		return m["text"], nil
 	}
 	if _, f := m["url"]; f {
-		return "", fmt.Errorf("'url' not supported")
+		return "", newDiagError(CodeUnsupported, "'url' not supported")
 	}
 	if _, f := m["copyFrom"]; f {
-		return "", fmt.Errorf("'url' not supported")
+		return "", newDiagError(CodeUnsupported, "'copyFrom' not supported")
 	}
 	if _, f := m["bigip"]; f {
 		return refers(m), nil
@@ -292,9 +459,13 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 		"description": strings.Split(parent, "/")[2], // parent string as '/partition/subfolder'
 	}
 	profiles := []interface{}{}
-	addrs := []string{}
+	addrs := []virtualAddr{}
+	shareAddrs := false
 	redirect80 := false
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
+		if err := checkServiceProperty(k, v); err != nil {
+			return err
+		}
 		switch k {
 		case "class":
 			switch v.(string) {
@@ -306,6 +477,8 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 				virtual["ipProtocol"] = "udp"
 			case "Service_HTTPS":
 				virtual["ipProtocol"] = "tcp"
+			case "Service_SCTP":
+				virtual["ipProtocol"] = "sctp"
 			default:
 				virtual["ipProtocol"] = "any"
 			}
@@ -356,6 +529,19 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 			profiles = append(profiles, map[string]interface{}{
 				"name": refers(v),
 			})
+		case "profileSCTP":
+			profiles = append(profiles, map[string]interface{}{
+				"name": refers(v),
+			})
+		case "forwardingType":
+			switch v {
+			case "ip":
+				virtual[cc.restname("ltm/virtual", "ip-forward")] = true
+			case "L2":
+				virtual[cc.restname("ltm/virtual", "l2-forward")] = true
+			default:
+				return fmt.Errorf("unknown forwardingType: %v", v)
+			}
 		case "profileL4":
 			if typeString(v) && v.(string) == "basic" {
 				profiles = append(profiles, map[string]interface{}{
@@ -373,7 +559,7 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 						"name": refers(sslprof),
 					})
 				}
-			} else if t == "string" {
+			} else if t == "string" || t == "map" {
 				profiles = append(profiles, map[string]interface{}{
 					"name": refers(v),
 				})
@@ -385,7 +571,7 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 						"name": refers(sslprof),
 					})
 				}
-			} else if t == "string" {
+			} else if t == "string" || t == "map" {
 				profiles = append(profiles, map[string]interface{}{
 					"name": refers(v),
 				})
@@ -411,64 +597,121 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 						"type": "snat",
 						"pool": fmt.Sprintf("%s-self", name),
 					}
+				default:
+					return newDiagError(CodeInvalidValue, "unknown snat: %v", v)
 				}
 			}
 		case "virtualAddresses":
-			for _, addr := range obj["virtualAddresses"].([]interface{}) {
-				t := reflect.TypeOf(addr).Kind().String()
-				if t == "map" {
-					if n, f := addr.(map[string]interface{})["use"]; f {
-						addrs = append(addrs, referToAddr(objsrc, n.(string)))
-					}
-				} else if t == "string" {
-					addrs = append(addrs, addr.(string))
-				} else {
-					return fmt.Errorf("virtualAddresses format is %s, not support yet", t)
+			items, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("virtualAddresses should be an array")
+			}
+			for i, item := range items {
+				va, err := cc.convertVirtualAddr(parent, item, objsrc)
+				if err != nil {
+					return fmt.Errorf("virtualAddresses %d: %s", i, err.Error())
 				}
+				addrs = append(addrs, va)
 			}
+		case "shareAddresses":
+			shareAddrs = v.(bool)
 		case "virtualPort":
+			if _, ok := v.(float64); !ok {
+				return fmt.Errorf("virtualPort should be a number")
+			}
 		case "mirroring":
 			if v.(string) == "none" {
-				virtual[restname("ltm/virtual", k)] = "disabled"
+				virtual[cc.restname("ltm/virtual", k)] = "disabled"
 			} else if v.(string) == "L4" {
-				virtual[restname("ltm/virtual", k)] = "enabled"
+				virtual[cc.restname("ltm/virtual", k)] = "enabled"
 			}
 		case "redirect80":
 			if v.(bool) {
 				redirect80 = true
 			}
+		case "policyEndpoint":
+			policies := []interface{}{}
+			if ls, ok := v.([]interface{}); ok {
+				for _, i := range ls {
+					policies = append(policies, map[string]interface{}{
+						"name": refers(i),
+					})
+				}
+			} else {
+				policies = append(policies, map[string]interface{}{
+					"name": refers(v),
+				})
+			}
+			virtual[cc.restname("ltm/virtual", "policies")] = policies
 		case "iRules":
 			if ls, ok := v.([]interface{}); ok {
 				rules := []string{}
 				for _, i := range ls {
 					rules = append(rules, refers(i))
 				}
-				virtual[restname("ltm/virtual", k)] = rules
+				virtual[cc.restname("ltm/virtual", k)] = rules
 			}
 		default:
 			dt, err := cc.convertByType("ltm/virtual", k, v)
 			if err != nil {
 				return err
 			}
-			virtual[restname("ltm/virtual", k)] = dt
+			virtual[cc.restname("ltm/virtual", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
+	switch obj["class"] {
+	case "Service_Forwarding":
+		// forwarding virtuals pass the traffic to the destination as it is, with no pool.
+		if _, f := obj["forwardingType"]; !f {
+			virtual[cc.restname("ltm/virtual", "ip-forward")] = true
+		}
+		virtual[cc.restname("ltm/virtual", "translateServerAddress")] = "disabled"
+		virtual[cc.restname("ltm/virtual", "translateServerPort")] = "disabled"
+		if len(profiles) == 0 {
+			profiles = append(profiles, map[string]interface{}{"name": "/Common/fastL4"})
+		}
+	case "Service_SCTP":
+		if _, f := obj["profileSCTP"]; !f {
+			profiles = append(profiles, map[string]interface{}{"name": "/Common/sctp"})
+		}
+	}
 	if _, f := virtual["pool"]; !f {
 		virtual["pool"] = ""
 	}
 	virtual["profiles"] = utils.SortIt(&profiles)
-	for i, addr := range addrs {
+	if shareAddrs {
+		// the virtual addresses shared among tenants are in /Common.
+		for i := range addrs {
+			if addrs[i].partition == "" {
+				addrs[i].partition = "Common"
+			}
+		}
+	}
+	// setDestination sets the destination, the mask and the source of the virtual to va and port.
+	setDestination := func(vobj map[string]interface{}, va virtualAddr, port interface{}) {
+		vobj["destination"] = addrPort(va.address, port)
+		if va.partition != "" {
+			vobj["destination"] = "/" + va.partition + "/" + vobj["destination"].(string)
+		}
+		if va.mask != "" {
+			vobj["mask"] = va.mask
+		}
+		if va.source != "" {
+			vobj["source"] = va.source
+		}
+	}
+	for i, va := range addrs {
+		addr := va.address
 		copiedvobj, err := utils.DeepCopy(virtual)
 		if err != nil {
 			return err
 		}
 		vobj := copiedvobj.(map[string]interface{})
-		if utils.IsIpv6(addr) {
-			vobj["destination"] = fmt.Sprintf("%s.%v", addr, obj["virtualPort"])
-		} else {
-			vobj["destination"] = fmt.Sprintf("%s:%v", addr, obj["virtualPort"])
-		}
+		setDestination(vobj, va, obj["virtualPort"])
 		if snattarget, f := obj["snat"]; f {
 			if t := refers(snattarget); t == "self" {
 				spname := indexedName(i, name) + "-self"
@@ -493,7 +736,7 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 				return err
 			}
 			vobj := copiedvobj.(map[string]interface{})
-			vobj["destination"] = fmt.Sprintf("%s:%v", addr, 80)
+			setDestination(vobj, va, 80)
 			vname := indexedName(i, name+"-Redirect-")
 			vobj["rules"] = []string{
 				"/Common/_sys_https_redirect",
@@ -512,32 +755,147 @@ func (cc *ConvertContext) convertVirtual(parent, name string, obj, objsrc, objds
 		}
 	}
 
-	for _, addr := range addrs {
-		if _, f := objdst["ltm/virtual-address/"+addr]; !f {
-			objdst["ltm/virtual-address/"+addr] = map[string]interface{}{
-				"name":    addr,
-				"address": addr,
+	for _, va := range addrs {
+		if va.ref {
+			// created by the Service_Address referred.
+			continue
+		}
+		if _, f := objdst["ltm/virtual-address/"+va.address]; !f {
+			vaobj := map[string]interface{}{
+				"name":    va.address,
+				"address": va.address,
 				"arp":     "enabled", //required, set default
 				// "arp":     "disabled", //required, as3 default
 			}
+			if va.mask != "" {
+				vaobj["mask"] = va.mask
+			}
+			if va.partition != "" {
+				vaobj["partition"] = va.partition
+			}
+			objdst["ltm/virtual-address/"+va.address] = vaobj
 		}
 	}
 	return nil
 }
 
-func (cc *ConvertContext) convertPool(name string, obj, objdst map[string]interface{}) error {
+// virtualAddr is one item of virtualAddresses of the as3 service.
+type virtualAddr struct {
+	// address with the route domain, i.e. 10.1.0.0%2
+	address string
+	// mask is set if the prefix length is given, i.e. 255.255.0.0 for 10.1.0.0/16
+	mask string
+	// source is set by the [destination, source] form, i.e. 192.168.0.0/16
+	source string
+	// partition is set if the virtual address is not of the service's partition, i.e. Common.
+	partition string
+	// ref tells it's of the Service_Address referred.
+	ref bool
+}
+
+// convertVirtualAddr converts the item of virtualAddresses, in the forms of:
+//
+//	"10.1.0.1", "10.1.0.0%2/16"        -> destination address, with the route domain and prefix length
+//	["10.1.0.1", "192.168.0.0/16"]     -> destination and source addresses
+//	{"use": "/T/A/serviceAddress"}     -> the virtualAddress of the Service_Address
+func (cc *ConvertContext) convertVirtualAddr(parent string, item interface{}, objsrc map[string]interface{}) (virtualAddr, error) {
+	va := virtualAddr{}
+	dest, srcBits := "", 0
+	switch t := reflect.TypeOf(item).Kind().String(); t {
+	case "string":
+		dest = item.(string)
+	case "map":
+		ref, ok := item.(map[string]interface{})["use"].(string)
+		if !ok {
+			return va, fmt.Errorf("virtual address should be referred by 'use'")
+		}
+		if dest = cc.referToAddr(parent, ref, objsrc); dest == "" {
+			return va, newDiagError(CodeDanglingReference, "Service_Address %s not found", ref)
+		}
+		va.ref = true
+		if strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, strings.Join(strings.Split(parent, "/")[:2], "/")+"/") {
+			va.partition = strings.Split(ref, "/")[1]
+		}
+	case "slice":
+		pair := item.([]interface{})
+		if len(pair) != 2 {
+			return va, fmt.Errorf("virtual address should be [destination, source]")
+		}
+		d, ok1 := pair[0].(string)
+		src, ok2 := pair[1].(string)
+		if !ok1 || !ok2 {
+			return va, fmt.Errorf("virtual address should be [destination, source] of strings")
+		}
+		saddr, smask, err := splitAddress(src)
+		if err != nil {
+			return va, fmt.Errorf("source: %s", err.Error())
+		}
+		ipv6 := utils.IsIpv6(strings.Split(saddr, "%")[0])
+		if daddr, _, err := splitAddress(d); err == nil && utils.IsIpv6(strings.Split(daddr, "%")[0]) != ipv6 {
+			return va, fmt.Errorf("destination %s and source %s should be of the same IP version", d, src)
+		}
+		bits := maskBits(smask)
+		if smask == "" {
+			bits = 32
+			if ipv6 {
+				bits = 128
+			}
+		}
+		dest, va.source, srcBits = d, saddr, bits
+	default:
+		return va, newDiagError(CodeUnsupported, "virtual address format %s not supported", t)
+	}
+	addr, mask, err := splitAddress(dest)
+	if err != nil {
+		return va, err
+	}
+	// the route domain of the tenant the address belongs to.
+	tenant := parent
+	if va.partition != "" {
+		tenant = va.partition
+	}
+	va.address, va.mask = rdAddress(addr, cc.routeDomain(tenant)), mask
+	if va.source != "" {
+		// the source without %N is in the route domain of the destination.
+		va.source = fmt.Sprintf("%s/%d", rdAddress(va.source, addrRouteDomain(va.address)), srcBits)
+		if addrRouteDomain(va.address) != addrRouteDomain(va.source) {
+			return va, fmt.Errorf("destination %s and source %s should be in the same route domain", va.address, va.source)
+		}
+	}
+	return va, nil
+}
+
+func (cc *ConvertContext) convertPool(parent, name string, obj, objdst map[string]interface{}) error {
 	pool := map[string]interface{}{
 		"name": name,
 	}
 
+	members := []interface{}{}
 	monitors := []string{}
 	monOps := ""
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "members":
-			// don't do member arrangement. do it at pool.members phrase.
+			items, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("pool members should be an array")
+			}
+			for i, item := range items {
+				member, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("pool member %d should be an object", i)
+				}
+				ms, err := cc.convertPoolMember(parent, name, member, objdst)
+				if err != nil {
+					return fmt.Errorf("pool member %d: %s", i, err.Error())
+				}
+				members = append(members, ms...)
+			}
 		case "monitors":
+			if err := checkRefs(k, v, false); err != nil {
+				return err
+			}
 			for _, m := range v.([]interface{}) {
 				mstr := refers(m)
 				// f5-appsvcs: mon = (mon === 'icmp') ? 'gateway_icmp' : mon;
@@ -547,18 +905,23 @@ func (cc *ConvertContext) convertPool(name string, obj, objdst map[string]interf
 				monitors = append(monitors, mstr)
 			}
 		case "minimumMonitors":
-			if _, ok := v.(string); !ok {
-				monOps = fmt.Sprintf("min %d of ", int(v.(float64)))
-			} else {
+			if v == "all" {
 				monOps = "all"
+			} else if n, ok := v.(float64); ok {
+				monOps = fmt.Sprintf("min %d of ", int(n))
+			} else {
+				return newDiagError(CodeInvalidValue, "minimumMonitors should be a number or 'all': %v", v)
 			}
 		default:
 			dt, err := cc.convertByType("ltm/pool", k, v)
 			if err != nil {
 				return err
 			}
-			pool[restname("ltm/pool", k)] = dt
+			pool[cc.restname("ltm/pool", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	pool["monitor"] = ""
@@ -570,41 +933,575 @@ func (cc *ConvertContext) convertPool(name string, obj, objdst map[string]interf
 			pool["monitor"] = monOps + strings.Join(monitors, " ")
 		}
 	}
+	pool["members"] = members
 	objdst["ltm/pool/"+name] = pool
 	return nil
 }
 
-func (cc *ConvertContext) convertMonitor(kn, name string, obj, objdst map[string]interface{}) error {
+// discoveryCA returns the PEM of the CA_Bundle referred by trustCA of the pool member discovered.
+func (cc *ConvertContext) discoveryCA(trustCA interface{}) (string, error) {
+	m, _ := trustCA.(map[string]interface{})
+	ref, ok := m["use"].(string)
+	if !ok {
+		return "", newDiagError(CodeUnsupported, "trustCA should refer to a CA_Bundle by 'use': %v", trustCA)
+	}
+	// ref is a full path resolved by resolveReferences, looked up in the whole parsed tree.
+	bundleobj, f := cc.lookup("fake_api/ca_bundle", "", ref, nil)
+	if !f {
+		return "", newDiagError(CodeDanglingReference, "CA_Bundle %s not found", ref)
+	}
+	bundle := bundleobj.(map[string]interface{})["bundle"]
+	if mb, ok := bundle.(map[string]interface{}); ok {
+		b, err := cc.convertF5string(mb)
+		if err != nil {
+			return "", err
+		}
+		bundle = b
+	}
+	pem, ok := bundle.(string)
+	if !ok {
+		return "", fmt.Errorf("cannot get the bundle of CA_Bundle %s", ref)
+	}
+	return pem, nil
+}
+
+// convertPoolMember expands one as3 pool member to the pool members of each server address,
+// the implied nodes are put to objdst as 'ltm/node/<name>',
+// nodes shared by 'shareNodes' are marked with partition Common.
+// The members of addressDiscovery other than static and fqdn are found by the MemberDiscoverer registered.
+func (cc *ConvertContext) convertPoolMember(parent, pool string, obj, objdst map[string]interface{}) ([]interface{}, error) {
+	port, f := obj["servicePort"]
+	if !f {
+		return nil, fmt.Errorf("servicePort is required")
+	}
+	// the member's routeDomain overrides the defaultRouteDomain of the tenant.
+	rd := cc.routeDomain(parent)
+	if v, f := obj["routeDomain"]; f {
+		if n, ok := v.(float64); ok {
+			if n < 0 || n > 65534 {
+				return nil, fmt.Errorf("routeDomain should be 0-65534: %v", v)
+			}
+			rd = int(n)
+		}
+	}
+	memberAddress := func(addr string) (string, error) {
+		a, mask, err := splitAddress(addr)
+		if err != nil {
+			return "", err
+		}
+		if mask != "" {
+			return "", fmt.Errorf("prefix length is not allowed in address %s", addr)
+		}
+		return rdAddress(a, rd), nil
+	}
+	shareNodes := false
+	if v, ok := obj["shareNodes"].(bool); ok {
+		shareNodes = v
+	}
+
+	// the same properties for all expanded members.
+	member := map[string]interface{}{
+		"session": "user-enabled",
+		"state":   "user-up",
+	}
+	monitors := []string{}
+	monOps := ""
+	for k, v := range obj {
+		switch k {
+		case "servicePort", "routeDomain", "shareNodes", "serverAddresses", "servers", "addressDiscovery", "enable":
+		case "hostname", "autoPopulate", "queryInterval", "addressFamily", "downInterval":
+		case "uri", "encodedToken", "trustCA", "rejectUnauthorized", "addressRealm", "updateInterval", "jmesPathQuery", "credentialUpdate":
+		case "adminState":
+			switch v {
+			case "enable":
+			case "disable":
+				member["session"] = "user-disabled"
+			case "offline":
+				member["session"] = "user-disabled"
+				member["state"] = "user-down"
+			default:
+				return nil, fmt.Errorf("unknown adminState: %v", v)
+			}
+		case "rateLimit":
+			if n, ok := v.(float64); ok && n < 0 {
+				member[cc.restname("ltm/pool/members", k)] = "disabled"
+			} else {
+				member[cc.restname("ltm/pool/members", k)] = v
+			}
+		case "monitors":
+			ms, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("monitors should be an array")
+			}
+			for _, m := range ms {
+				mstr := refers(m)
+				if mstr == "icmp" {
+					mstr = "gateway_icmp"
+				}
+				monitors = append(monitors, mstr)
+			}
+		case "minimumMonitors":
+			if n, ok := v.(float64); ok {
+				monOps = fmt.Sprintf("min %d of ", int(n))
+			} else {
+				monOps = "all"
+			}
+		default:
+			dt, err := cc.convertByType("ltm/pool/members", k, v)
+			if err != nil {
+				return nil, err
+			}
+			member[cc.restname("ltm/pool/members", k)] = dt
+		}
+	}
+	member["monitor"] = "default"
+	if len(monitors) > 0 {
+		if monOps == "all" {
+			member["monitor"] = strings.Join(monitors, " and ")
+		} else {
+			member["monitor"] = monOps + strings.Join(monitors, " ")
+		}
+	}
+	if enable, ok := obj["enable"].(bool); ok && !enable {
+		// 'enable: false' overrides adminState.
+		member["session"] = "user-disabled"
+		member["state"] = "user-down"
+	}
+
+	if obj["addressDiscovery"] == "fqdn" {
+		return cc.convertFqdnMember(obj, member, port, shareNodes, objdst)
+	}
+
+	// name, address and port of the nodes.
+	servers := [][3]string{}
+	sport := fmt.Sprintf("%v", port)
+	if addrs, f := obj["serverAddresses"]; f {
+		items, ok := addrs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("serverAddresses should be an array")
+		}
+		for _, item := range items {
+			addr, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("serverAddresses item should be string: %v", item)
+			}
+			addr, err := memberAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, [3]string{addr, addr, sport})
+		}
+	}
+	if svrs, f := obj["servers"]; f {
+		items, ok := svrs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("servers should be an array")
+		}
+		for _, item := range items {
+			svr, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("servers item should be an object: %v", item)
+			}
+			name, ok1 := svr["name"].(string)
+			addr, ok2 := svr["address"].(string)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("servers item requires name and address: %v", item)
+			}
+			addr, err := memberAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, [3]string{name, addr, sport})
+		}
+	}
+	if ad, ok := obj["addressDiscovery"].(string); ok && ad != "static" {
+		d, f := cc.discoverers[ad]
+		if !f {
+			return nil, newDiagError(CodeUnsupported, "addressDiscovery %s not supported, no MemberDiscoverer registered", ad)
+		}
+		if ca, f := obj["trustCA"]; f {
+			pem, err := cc.discoveryCA(ca)
+			if err != nil {
+				return nil, err
+			}
+			copied := map[string]interface{}{}
+			for k, v := range obj {
+				copied[k] = v
+			}
+			copied["trustCA"] = pem
+			obj = copied
+		}
+		discovered, err := d.Discover(cc, parent+"/"+pool, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover the members of %s: %s", ad, err.Error())
+		}
+		for _, dm := range discovered {
+			addr, err := memberAddress(dm.Address)
+			if err != nil {
+				return nil, fmt.Errorf("discovered member: %s", err.Error())
+			}
+			mport := sport
+			if dm.Port > 0 {
+				mport = fmt.Sprintf("%d", dm.Port)
+			}
+			servers = append(servers, [3]string{addr, addr, mport})
+		}
+	}
+
+	members := []interface{}{}
+	for _, svr := range servers {
+		nodename, addr, mport := svr[0], svr[1], svr[2]
+		copiedmobj, err := utils.DeepCopy(member)
+		if err != nil {
+			return nil, err
+		}
+		mobj := copiedmobj.(map[string]interface{})
+		mobj["name"] = addrPort(nodename, mport)
+		mobj["address"] = addr
+
+		node := map[string]interface{}{
+			"name":    nodename,
+			"address": addr,
+		}
+		if shareNodes {
+			mobj["name"] = "/Common/" + mobj["name"].(string)
+			node["partition"] = "Common"
+		}
+		objdst["ltm/node/"+nodename] = node
+		members = append(members, mobj)
+	}
+	return members, nil
+}
+
+// convertFqdnMember converts the member of 'addressDiscovery: fqdn' to the member '<hostname>:<port>',
+// and the FQDN node named by the hostname, which resolves the addresses, and populates the members if autoPopulate.
+func (cc *ConvertContext) convertFqdnMember(obj, member map[string]interface{}, port interface{}, shareNodes bool, objdst map[string]interface{}) ([]interface{}, error) {
+	hostname, ok := obj["hostname"].(string)
+	if !ok || hostname == "" {
+		return nil, fmt.Errorf("hostname is required for addressDiscovery fqdn")
+	}
+	autoPopulate := false
+	if v, ok := obj["autoPopulate"].(bool); ok {
+		autoPopulate = v
+	}
+
+	// f5-appsvcs: queryInterval 0 means to query again per the TTL of the DNS records.
+	fqdn := map[string]interface{}{
+		cc.restname("ltm/node/fqdn", "hostname"):       hostname,
+		cc.restname("ltm/node/fqdn", "autoPopulate"):   cc.convertBool("ltm/node/fqdn", "autoPopulate", autoPopulate),
+		cc.restname("ltm/node/fqdn", "queryInterval"):  "ttl",
+		cc.restname("ltm/node/fqdn", "address-family"): "ipv4",
+		cc.restname("ltm/node/fqdn", "down-interval"):  5,
+	}
+	for as3name, tmname := range map[string]string{"queryInterval": "queryInterval", "addressFamily": "address-family", "downInterval": "down-interval"} {
+		v, f := obj[as3name]
+		if !f {
+			continue
+		}
+		if n, ok := v.(float64); ok && as3name == "queryInterval" && n == 0 {
+			continue
+		}
+		if as3name == "addressFamily" {
+			family, ok := v.(string)
+			family = strings.ToLower(family)
+			if !ok || (family != "ipv4" && family != "ipv6") {
+				return nil, newDiagError(CodeInvalidValue, "addressFamily should be ipv4 or ipv6: %v", v)
+			}
+			v = family
+		}
+		fqdn[cc.restname("ltm/node/fqdn", tmname)] = v
+	}
+	node := map[string]interface{}{
+		"name": hostname,
+		"fqdn": fqdn,
+	}
+
+	member["name"] = addrPort(hostname, port)
+	member["fqdn"] = map[string]interface{}{
+		cc.restname("ltm/node/fqdn", "hostname"):             hostname,
+		cc.restname("ltm/pool/members/fqdn", "autopopulate"): cc.convertBool("ltm/pool/members/fqdn", "autopopulate", autoPopulate),
+	}
+	if shareNodes {
+		member["name"] = "/Common/" + member["name"].(string)
+		node["partition"] = "Common"
+	}
+	objdst["ltm/node/"+hostname] = node
+	return []interface{}{member}, nil
+}
+
+// monitorConverters convert the monitor type specific properties,
+// the handled properties are removed from obj, the left ones are converted as common properties.
+// The monitor types without one, i.e. dns, have no property to convert other than the common ones.
+var monitorConverters = map[string]func(cc *ConvertContext, parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error{
+	"http":          (*ConvertContext).convertAdaptiveMonitor,
+	"https":         (*ConvertContext).convertHttpsMonitor,
+	"tcp":           (*ConvertContext).convertAdaptiveMonitor,
+	"tcp-half-open": (*ConvertContext).convertAdaptiveMonitor,
+	"udp":           (*ConvertContext).convertAdaptiveMonitor,
+	"icmp":          (*ConvertContext).convertAdaptiveMonitor,
+	"mysql":         (*ConvertContext).convertMysqlMonitor,
+	"external":      (*ConvertContext).convertExternalMonitor,
+	"ldap":          (*ConvertContext).convertLdapMonitor,
+	"sip":           (*ConvertContext).convertSipMonitor,
+	"inband":        (*ConvertContext).convertInbandMonitor,
+}
+
+func (cc *ConvertContext) convertMonitor(parent, kn, name string, obj, objsrc, objdst map[string]interface{}) error {
 	monitor := map[string]interface{}{
 		"name": name,
 	}
-	for k, v := range obj {
+	copiedobj, err := utils.DeepCopy(obj)
+	if err != nil {
+		return err
+	}
+	props := copiedobj.(map[string]interface{})
+	t, ok := props["monitorType"].(string)
+	if !ok {
+		return fmt.Errorf("monitorType is required")
+	}
+	if convert, f := monitorConverters[t]; f {
+		if err := convert(cc, parent, name, props, monitor, objsrc, objdst); err != nil {
+			return err
+		}
+	}
+
+	addr, fa := props["targetAddress"]
+	port, fp := props["targetPort"]
+	if fa || fp {
+		if addr == nil || addr == "" {
+			addr = "*"
+		}
+		if port == nil || port == 0.0 {
+			port = "*"
+		}
+		if t == "icmp" {
+			// icmp monitors have no port in destination.
+			monitor["destination"] = fmt.Sprintf("%v", addr)
+		} else {
+			monitor["destination"] = addrPort(fmt.Sprintf("%v", addr), port)
+		}
+	}
+
+	if err := eachProperty(props, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "monitorType":
-		case "send":
-			str := v.(string)
-			str = strings.ReplaceAll(str, "\r", "\\r")
-			str = strings.ReplaceAll(str, "\n", "\\n")
-			monitor[restname("ltm/monitor", k)] = str
-		case "receive":
-			str := v.(string)
+		case "targetAddress", "targetPort":
+		case "send", "receive", "receiveDown":
+			str, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s should be string", k)
+			}
 			str = strings.ReplaceAll(str, "\r", "\\r")
 			str = strings.ReplaceAll(str, "\n", "\\n")
-			monitor[restname("ltm/monitor", k)] = str
+			monitor[cc.restname("ltm/monitor", k)] = str
+		case "passphrase":
+			pass, err := cc.convertSecret(v)
+			if err != nil {
+				return err
+			}
+			monitor[cc.restname("ltm/monitor", k)] = pass
 		default:
 			dt, err := cc.convertByType("ltm/monitor", k, v)
 			if err != nil {
 				return err
 			}
-			monitor[restname("ltm/monitor", k)] = dt
+			monitor[cc.restname("ltm/monitor", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst[kn] = monitor
 	return nil
 }
 
+// convertAdaptiveMonitor converts the adaptive response time monitoring of http, https, tcp, tcp-half-open, udp and icmp.
+// As f5-appsvcs does, the divergence value is taken by adaptiveDivergenceType, and the adaptive properties are
+// dropped unless adaptive is true.
+func (cc *ConvertContext) convertAdaptiveMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	adaptive, _ := obj["adaptive"].(bool)
+	if adaptive {
+		divType := "relative"
+		if v, f := obj["adaptiveDivergenceType"]; f {
+			if divType, _ = v.(string); divType != "relative" && divType != "absolute" {
+				ds := Diagnostics{}
+				ds.add("adaptiveDivergenceType", newDiagError(CodeInvalidValue, "adaptiveDivergenceType should be relative or absolute: %v", v))
+				return ds.err()
+			}
+		}
+		monitor[cc.restname("ltm/monitor", "adaptive-divergence-type")] = divType
+		divValue := "adaptiveDivergencePercentage"
+		if divType == "absolute" {
+			divValue = "adaptiveDivergenceMilliseconds"
+		}
+		if v, f := obj[divValue]; f {
+			monitor[cc.restname("ltm/monitor", "adaptiveDivergencePercentage")] = v
+		}
+	} else {
+		delete(obj, "adaptiveLimitMilliseconds")
+		delete(obj, "adaptiveWindow")
+	}
+	for _, k := range []string{"adaptiveDivergenceType", "adaptiveDivergencePercentage", "adaptiveDivergenceMilliseconds"} {
+		delete(obj, k)
+	}
+	return nil
+}
+
+func (cc *ConvertContext) convertHttpsMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	if err := cc.convertAdaptiveMonitor(parent, name, obj, monitor, objsrc, objdst); err != nil {
+		return err
+	}
+	if v, f := obj["clientTLS"]; f {
+		monitor[cc.restname("ltm/monitor", "clientTLS")] = refers(v)
+		delete(obj, "clientTLS")
+	}
+	if v, f := obj["clientCertificate"]; f {
+		refs, err := cc.certificateRefs(parent, v, objsrc)
+		if err != nil {
+			return err
+		}
+		for _, rk := range []string{"cert", "key"} {
+			if rv, f := refs[rk]; f {
+				monitor[rk] = rv
+			}
+		}
+		delete(obj, "clientCertificate")
+	}
+	return nil
+}
+
+// convertMysqlMonitor converts the query result checks, the row and column of the result are strings in iControl REST.
+func (cc *ConvertContext) convertMysqlMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	if v, f := obj["count"]; f {
+		if n, ok := v.(float64); !ok || n < 0 || n != float64(int64(n)) {
+			ds := Diagnostics{}
+			ds.add("count", newDiagError(CodeInvalidValue, "count should be a non-negative integer: %v", v))
+			return ds.err()
+		}
+	}
+	for _, k := range []string{"receiveRow", "receiveColumn"} {
+		v, f := obj[k]
+		if !f {
+			continue
+		}
+		switch tv := v.(type) {
+		case string:
+			monitor[cc.restname("ltm/monitor", k)] = tv
+		case float64:
+			monitor[cc.restname("ltm/monitor", k)] = strconv.FormatFloat(tv, 'f', -1, 64)
+		default:
+			ds := Diagnostics{}
+			ds.add(k, newDiagError(CodeInvalidValue, "%s should be a string or number: %v", k, v))
+			return ds.err()
+		}
+		delete(obj, k)
+	}
+	return nil
+}
+
+func (cc *ConvertContext) convertExternalMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	uploadsUrl := "shared/file-transfer/uploads"
+	fileDir := "file:/var/config/rest/downloads"
+
+	if v, f := obj["script"]; f {
+		script := v
+		if m, ok := v.(map[string]interface{}); ok {
+			s, err := cc.convertF5string(m)
+			if err != nil {
+				return err
+			}
+			script = s
+		}
+		content, ok := script.(string)
+		if !ok {
+			return fmt.Errorf("cannot get the content of external monitor script")
+		}
+		pf := strings.Split(parent, "/") // fmt: /Sample_02/A1
+		scriptname := name + "-script"
+		filename := fmt.Sprintf("_%s__%s__%s", pf[1], pf[2], scriptname)
+		objdst[uploadsUrl+"/"+filename] = map[string]interface{}{
+			"content": content,
+		}
+		objdst["sys/file/external-monitor/"+scriptname] = map[string]interface{}{
+			"name":       scriptname,
+			"sourcePath": fmt.Sprintf("%s/%s", fileDir, filename),
+		}
+		monitor[cc.restname("ltm/monitor", "pathname")] = parent + "/" + scriptname
+		delete(obj, "script")
+	}
+	if v, f := obj["environmentVariables"]; f {
+		vars, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("environmentVariables should be an object")
+		}
+		// user defined variables are represented as 'userDefined <name>' in iControl REST.
+		raw := map[string]interface{}{}
+		for vk, vv := range vars {
+			raw["userDefined "+vk] = vv
+		}
+		monitor["apiRawValues"] = raw
+		delete(obj, "environmentVariables")
+	}
+	return nil
+}
+
+func (cc *ConvertContext) convertLdapMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	for as3name, restkey := range map[string]string{
+		"chaseReferrals":      "chase-referrals",
+		"mandatoryAttributes": "mandatory-attributes",
+	} {
+		if v, f := obj[as3name]; f {
+			if b, ok := v.(bool); ok {
+				monitor[cc.restname("ltm/monitor", restkey)] = cc.convertBool("ltm/monitor", restkey, b)
+			} else {
+				monitor[cc.restname("ltm/monitor", restkey)] = v
+			}
+			delete(obj, as3name)
+		}
+	}
+	return nil
+}
+
+func (cc *ConvertContext) convertSipMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	for _, k := range []string{"codesUp", "codesDown"} {
+		if v, f := obj[k]; f {
+			if codes, ok := v.([]interface{}); ok {
+				strs := []string{}
+				for _, c := range codes {
+					strs = append(strs, fmt.Sprintf("%v", c))
+				}
+				monitor[cc.restname("ltm/monitor", k)] = strings.Join(strs, " ")
+			} else {
+				monitor[cc.restname("ltm/monitor", k)] = v
+			}
+			delete(obj, k)
+		}
+	}
+	if v, f := obj["headers"]; f {
+		if headers, ok := v.(string); ok {
+			monitor[cc.restname("ltm/monitor", "headers")] = headers
+		} else if headers, ok := v.([]interface{}); ok {
+			strs := []string{}
+			for _, h := range headers {
+				strs = append(strs, fmt.Sprintf("%v", h))
+			}
+			monitor[cc.restname("ltm/monitor", "headers")] = strings.Join(strs, "\\r\\n")
+		}
+		delete(obj, "headers")
+	}
+	return cc.convertHttpsMonitor(parent, name, obj, monitor, objsrc, objdst)
+}
+
+func (cc *ConvertContext) convertInbandMonitor(parent, name string, obj, monitor, objsrc, objdst map[string]interface{}) error {
+	// inband monitors check the passive traffic, no destination or intervals.
+	for _, k := range []string{"targetAddress", "targetPort", "interval", "timeout", "upInterval", "timeUntilUp"} {
+		delete(obj, k)
+	}
+	return nil
+}
+
 func (cc *ConvertContext) convertProfile(parent, kind, name string, obj, objsrc, objdst map[string]interface{}) error {
 	switch kind {
 	case "http":
@@ -634,7 +1531,7 @@ func (cc *ConvertContext) convertServersslProfile(parent, kind, name string, obj
 		"caFile": "/Common/ca-bundle.crt", // by default, however, it's rarely in product case.
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "trustCA":
@@ -647,102 +1544,144 @@ func (cc *ConvertContext) convertServersslProfile(parent, kind, name string, obj
 				}
 			} else if t == "map" {
 				profile["caFile"] = refers(v)
+			} else {
+				return newDiagError(CodeInvalidValue, "trustCA should be 'generic' or a reference: %v", v)
 			}
 		case "clientCertificate":
-			ckname := v.(string)
-			clscert := fmt.Sprintf("fake_api/certificate/%s", ckname)
-			if cert, f := objsrc[clscert]; f {
-				certobj := cert.(map[string]interface{})
-				if crt, f := certobj["certificate"]; f {
-					profile["cert"] = tlsRefers(ckname+".crt", parent, crt)
-				}
-				if pkey, f := certobj["privateKey"]; f {
-					profile["key"] = tlsRefers(ckname+".key", parent, pkey)
-				}
-				if ca, f := certobj["chainCA"]; f {
-					profile["chain"] = tlsRefers(ckname+"-bundle.crt", parent, ca)
-				}
-				if pass, f := certobj["passphrase"]; f {
-					if pass, err := cc.convertSecret(pass); err != nil {
-						return err
-					} else {
-						profile["passphrase"] = pass
-					}
-				}
+			refs, err := cc.certificateRefs(parent, v, objsrc)
+			if err != nil {
+				return err
+			}
+			for rk, rv := range refs {
+				profile[rk] = rv
 			}
 		case "authenticationFrequency":
+			if _, ok := v.(string); !ok {
+				return newDiagError(CodeInvalidValue, "authenticationFrequency should be a string: %v", v)
+			}
 			value := strings.ReplaceAll(v.(string), "one-time", "once")
 			value = strings.ReplaceAll(value, "every-time", "always")
-			profile[restname("ltm/profile/"+kind, k)] = value
+			profile[cc.restname("ltm/profile/"+kind, k)] = value
 		default:
 			dt, err := cc.convertByType("ltm/profile/"+kind, k, v)
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/"+kind, k)] = dt
+			profile[cc.restname("ltm/profile/"+kind, k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst["ltm/profile/"+kind+"/"+name] = profile
 	return nil
 }
 
+// certificateRefs returns the cert, key, chain and passphrase of the Certificate referred by obj.
+func (cc *ConvertContext) certificateRefs(parent string, obj interface{}, objsrc map[string]interface{}) (map[string]interface{}, error) {
+	refs := map[string]interface{}{}
+	ckname, ok := obj.(string)
+	if !ok {
+		if m, ok := obj.(map[string]interface{}); ok {
+			if bigip, f := m["bigip"]; f {
+				refs["cert"] = bigip
+				return refs, nil
+			}
+		}
+		ckname = refers(obj)
+	}
+	folder, name := splitPath(parent, ckname)
+	if cert, f := cc.lookup("fake_api/certificate", parent, ckname, objsrc); f {
+		certobj := cert.(map[string]interface{})
+		if crt, f := certobj["certificate"]; f {
+			refs["cert"] = tlsRefers(name+".crt", folder, crt)
+		}
+		if pkey, f := certobj["privateKey"]; f {
+			refs["key"] = tlsRefers(name+".key", folder, pkey)
+		}
+		if ca, f := certobj["chainCA"]; f {
+			refs["chain"] = tlsRefers(name+"-bundle.crt", folder, ca)
+		}
+		if pass, f := certobj["passphrase"]; f {
+			if pass, err := cc.convertSecret(pass); err != nil {
+				return refs, err
+			} else {
+				refs["passphrase"] = pass
+			}
+		}
+	}
+	return refs, nil
+}
+
 func (cc *ConvertContext) convertClientsslProfile(parent, kind, name string, obj, objsrc, objdst map[string]interface{}) error {
 	profiles := map[string]interface{}{}
 	pcommon := map[string]interface{}{}
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "certificates":
-			if items, ok := v.([]interface{}); ok {
-				for i, item := range items {
-					n, sniDefault := name, "true"
-					if i != 0 {
-						n = fmt.Sprintf("%s-%d-", n, i)
-						sniDefault = "false"
-					}
+			items, ok := v.([]interface{})
+			if !ok {
+				return newDiagError(CodeInvalidValue, "certificates should be an array: %v", v)
+			}
+			for i, item := range items {
+				if mitem, ok := item.(map[string]interface{}); !ok {
+					return newDiagError(CodeInvalidValue, "certificates item %d should be an object: %v", i, item)
+				} else if _, ok := mitem["certificate"].(string); !ok {
+					return newDiagError(CodeInvalidValue, "certificates item %d requires the certificate name: %v", i, item)
+				}
+			}
+			for i, item := range items {
+				n, sniDefault := name, "true"
+				if i != 0 {
+					n = fmt.Sprintf("%s-%d-", n, i)
+					sniDefault = "false"
+				}
 
-					profile := map[string]interface{}{
-						"name":       n,
-						"sniDefault": sniDefault,
-						"serverName": "none",
-					}
-					if ckname, f := item.(map[string]interface{})["certificate"]; f {
-						if matchToSNI, f := item.(map[string]interface{})["matchToSNI"]; f {
-							if sn, ok := matchToSNI.(string); ok {
-								profile["serverName"] = sn
-							}
+				profile := map[string]interface{}{
+					"name":       n,
+					"sniDefault": sniDefault,
+					"serverName": "none",
+				}
+				if ckname, f := item.(map[string]interface{})["certificate"]; f {
+					if matchToSNI, f := item.(map[string]interface{})["matchToSNI"]; f {
+						if sn, ok := matchToSNI.(string); ok {
+							profile["serverName"] = sn
 						}
+					}
 
-						clscert := fmt.Sprintf("fake_api/certificate/%s", ckname)
-						if cert, f := objsrc[clscert]; f {
-							certobj := cert.(map[string]interface{})
-							if crt, f := certobj["certificate"]; f {
-								profile["cert"] = tlsRefers(ckname.(string)+".crt", parent, crt)
-							}
-							if pkey, f := certobj["privateKey"]; f {
-								profile["key"] = tlsRefers(ckname.(string)+".key", parent, pkey)
-							}
-							if ca, f := certobj["chainCA"]; f {
-								profile["chain"] = tlsRefers(ckname.(string)+"-bundle.crt", parent, ca)
-							}
-							if pass, f := certobj["passphrase"]; f {
-								if pass, err := cc.convertSecret(pass); err != nil {
-									return err
-								} else {
-									profile["passphrase"] = pass
-								}
+					clscert := fmt.Sprintf("fake_api/certificate/%s", ckname)
+					if cert, f := objsrc[clscert]; f {
+						certobj := cert.(map[string]interface{})
+						if crt, f := certobj["certificate"]; f {
+							profile["cert"] = tlsRefers(ckname.(string)+".crt", parent, crt)
+						}
+						if pkey, f := certobj["privateKey"]; f {
+							profile["key"] = tlsRefers(ckname.(string)+".key", parent, pkey)
+						}
+						if ca, f := certobj["chainCA"]; f {
+							profile["chain"] = tlsRefers(ckname.(string)+"-bundle.crt", parent, ca)
+						}
+						if pass, f := certobj["passphrase"]; f {
+							if pass, err := cc.convertSecret(pass); err != nil {
+								return err
+							} else {
+								profile["passphrase"] = pass
 							}
 						}
-
-						profiles[profile["name"].(string)] = profile
 					}
+
+					profiles[profile["name"].(string)] = profile
 				}
 			}
 		case "authenticationFrequency":
+			if _, ok := v.(string); !ok {
+				return newDiagError(CodeInvalidValue, "authenticationFrequency should be a string: %v", v)
+			}
 			value := strings.ReplaceAll(v.(string), "one-time", "once")
 			value = strings.ReplaceAll(value, "every-time", "always")
-			pcommon[restname("ltm/profile/"+kind, k)] = value
+			pcommon[cc.restname("ltm/profile/"+kind, k)] = value
 		case "authenticationTrustCA":
 			t := reflect.TypeOf(v).Kind().String()
 			if t == "map" {
@@ -757,14 +1696,19 @@ func (cc *ConvertContext) convertClientsslProfile(parent, kind, name string, obj
 				} else {
 					return fmt.Errorf("cannot convert ca_bundle for authenticationTrustCA: %v", v)
 				}
+			} else {
+				return newDiagError(CodeInvalidValue, "authenticationTrustCA should be a reference: %v", v)
 			}
 		default:
 			dt, err := cc.convertByType("ltm/profile/"+kind, k, v)
 			if err != nil {
 				return err
 			}
-			pcommon[restname("ltm/profile/"+kind, k)] = dt
+			pcommon[cc.restname("ltm/profile/"+kind, k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	for pname, p := range profiles {
 		profile := p.(map[string]interface{})
@@ -781,7 +1725,7 @@ func (cc *ConvertContext) convertCommonProfile(kind, name string, obj, objdst ma
 		"name": name,
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		default:
@@ -789,8 +1733,11 @@ func (cc *ConvertContext) convertCommonProfile(kind, name string, obj, objdst ma
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/"+kind, k)] = dt
+			profile[cc.restname("ltm/profile/"+kind, k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	objdst["ltm/profile/"+kind+"/"+name] = profile
 	return nil
@@ -801,7 +1748,7 @@ func (cc *ConvertContext) convertUdpProfile(name string, obj, objdst map[string]
 		"name": name,
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		default:
@@ -809,8 +1756,11 @@ func (cc *ConvertContext) convertUdpProfile(name string, obj, objdst map[string]
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/udp", k)] = dt
+			profile[cc.restname("ltm/profile/udp", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	objdst["ltm/profile/udp/"+name] = profile
 	return nil
@@ -821,22 +1771,25 @@ func (cc *ConvertContext) convertTcpProfile(name string, obj, objdst map[string]
 		"name": name,
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "mptcp":
 			// f5-appsvcs
 			// if (item.mptcp !== 'passthrough') item.mptcp += 'd';
 			if v.(string) != "passthrough" {
-				profile[restname("ltm/profile/tcp", k)] = v.(string) + "d"
+				profile[cc.restname("ltm/profile/tcp", k)] = v.(string) + "d"
 			}
 		default:
 			dt, err := cc.convertByType("ltm/profile/tcp", k, v)
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/tcp", k)] = dt
+			profile[cc.restname("ltm/profile/tcp", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	objdst["ltm/profile/tcp/"+name] = profile
 	return nil
@@ -847,7 +1800,7 @@ func (cc *ConvertContext) convertFtpProfile(name string, obj, objdst map[string]
 		"name": name,
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		default:
@@ -855,8 +1808,11 @@ func (cc *ConvertContext) convertFtpProfile(name string, obj, objdst map[string]
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/ftp", k)] = dt
+			profile[cc.restname("ltm/profile/ftp", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst["ltm/profile/ftp/"+name] = profile
@@ -870,34 +1826,34 @@ func (cc *ConvertContext) convertHttpProfile(name string, obj, objdst map[string
 
 	//  HTTP Strict Transport Security.
 	hsts := map[string]interface{}{}
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		case "responseChunking":
-			if bigip.Version >= "15." && (v.(string) == "selective" || v.(string) == "preserve") {
+			if cc.version >= "15." && (v.(string) == "selective" || v.(string) == "preserve") {
 				profile["responseChunking"] = "sustain"
 			} else {
 				dt, err := cc.convertByType("ltm/profile/http", k, v)
 				if err != nil {
 					return err
 				}
-				profile[restname("ltm/profile/http", k)] = dt
+				profile[cc.restname("ltm/profile/http", k)] = dt
 			}
 		case "requestChunking":
-			if bigip.Version >= "15." && (v.(string) == "selective" || v.(string) == "preserve") {
+			if cc.version >= "15." && (v.(string) == "selective" || v.(string) == "preserve") {
 				profile["requestChunking"] = "sustain"
 			} else {
 				dt, err := cc.convertByType("ltm/profile/http", k, v)
 				if err != nil {
 					return err
 				}
-				profile[restname("ltm/profile/http", k)] = dt
+				profile[cc.restname("ltm/profile/http", k)] = dt
 			}
 		case "insertHeader":
 			name, f1 := v.(map[string]interface{})["name"]
 			value, f2 := v.(map[string]interface{})["value"]
 			if f1 && f2 {
-				profile[restname("ltm/profile/http", k)] = fmt.Sprintf("%s: %s", name, value)
+				profile[cc.restname("ltm/profile/http", k)] = fmt.Sprintf("%s: %s", name, value)
 			}
 		default:
 			if strings.Index(k, "hsts") == 0 {
@@ -907,8 +1863,11 @@ func (cc *ConvertContext) convertHttpProfile(name string, obj, objdst map[string
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/http", k)] = dt
+			profile[cc.restname("ltm/profile/http", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	opt := cc.convertHttpProfileHsts(hsts)
 	copiedhsts, err := utils.DeepCopy(*opt)
@@ -928,7 +1887,7 @@ func (cc *ConvertContext) convertHttpProfileHsts(hsts map[string]interface{}) *m
 			nk := strings.Replace(k, "hsts", "", 1)
 			nk = strings.ToLower(string(nk[0])) + nk[1:]
 			dt, _ := cc.convertByType("ltm/profile/http/hsts", nk, v)
-			opt[restname("ltm/profile/http/hsts", nk)] = dt
+			opt[cc.restname("ltm/profile/http/hsts", nk)] = dt
 		}
 	}
 
@@ -940,7 +1899,7 @@ func (cc *ConvertContext) convertOneconnectProfile(name string, obj, objdst map[
 		"name": name,
 	}
 
-	for k, v := range obj {
+	if err := eachProperty(obj, func(k string, v interface{}) error {
 		switch k {
 		case "class":
 		default:
@@ -948,10 +1907,381 @@ func (cc *ConvertContext) convertOneconnectProfile(name string, obj, objdst map[
 			if err != nil {
 				return err
 			}
-			profile[restname("ltm/profile/one-connect", k)] = dt
+			profile[cc.restname("ltm/profile/one-connect", k)] = dt
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	objdst["ltm/profile/one-connect/"+name] = profile
 	return nil
 }
+
+func (cc *ConvertContext) convertPolicy(name string, obj, objdst map[string]interface{}) error {
+	policy := map[string]interface{}{
+		"name":     name,
+		"strategy": "/Common/first-match",
+		// create it as a published policy directly, no draft needed.
+		"legacy": true,
+	}
+	requires, controls := []string{}, []string{}
+	if err := eachProperty(obj, func(k string, v interface{}) error {
+		switch k {
+		case "class":
+		case "strategy":
+			if s, ok := v.(string); ok {
+				policy[cc.restname("ltm/policy", k)] = "/Common/" + s
+			} else {
+				policy[cc.restname("ltm/policy", k)] = refers(v)
+			}
+		case "rules":
+			items, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("policy rules should be an array")
+			}
+			rules := []interface{}{}
+			for i, item := range items {
+				r, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("policy rule %d should be an object", i)
+				}
+				rule, rqs, cts, err := cc.convertPolicyRule(i, r)
+				if err != nil {
+					return fmt.Errorf("policy rule %d: %s", i, err.Error())
+				}
+				requires = append(requires, rqs...)
+				controls = append(controls, cts...)
+				rules = append(rules, rule)
+			}
+			policy[cc.restname("ltm/policy", k)] = rules
+		default:
+			dt, err := cc.convertByType("ltm/policy", k, v)
+			if err != nil {
+				return err
+			}
+			policy[cc.restname("ltm/policy", k)] = dt
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	// sorted for the same output every time, utils.Unified doesn't keep the order.
+	requires, controls = utils.Unified(requires), utils.Unified(controls)
+	sort.Strings(requires)
+	sort.Strings(controls)
+	policy["requires"] = requires
+	policy["controls"] = controls
+
+	objdst["ltm/policy/"+name] = policy
+	return nil
+}
+
+// convertPolicyRule converts an as3 policy rule, it returns the rule,
+// and what the rule requires and controls, i.e. 'http' and 'forwarding'.
+func (cc *ConvertContext) convertPolicyRule(ordinal int, obj map[string]interface{}) (map[string]interface{}, []string, []string, error) {
+	requires, controls := []string{}, []string{}
+	rule := map[string]interface{}{
+		"ordinal":    ordinal,
+		"conditions": []interface{}{},
+		"actions":    []interface{}{},
+	}
+	for k, v := range obj {
+		switch k {
+		case "conditions":
+			items, ok := v.([]interface{})
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("conditions should be an array")
+			}
+			conditions := []interface{}{}
+			for i, item := range items {
+				c, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, nil, nil, fmt.Errorf("condition %d should be an object", i)
+				}
+				condition, rqs, err := cc.convertPolicyCondition(c)
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("condition %d: %s", i, err.Error())
+				}
+				condition["name"] = fmt.Sprintf("%d", i)
+				requires = append(requires, rqs...)
+				conditions = append(conditions, condition)
+			}
+			rule[cc.restname("ltm/policy/rules", k)] = conditions
+		case "actions":
+			items, ok := v.([]interface{})
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("actions should be an array")
+			}
+			actions := []interface{}{}
+			for i, item := range items {
+				a, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, nil, nil, fmt.Errorf("action %d should be an object", i)
+				}
+				action, rqs, cts, err := cc.convertPolicyAction(a)
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("action %d: %s", i, err.Error())
+				}
+				action["name"] = fmt.Sprintf("%d", i)
+				requires = append(requires, rqs...)
+				controls = append(controls, cts...)
+				actions = append(actions, action)
+			}
+			rule[cc.restname("ltm/policy/rules", k)] = actions
+		default:
+			dt, err := cc.convertByType("ltm/policy/rules", k, v)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			rule[cc.restname("ltm/policy/rules", k)] = dt
+		}
+	}
+	if _, f := rule["name"]; !f {
+		return nil, nil, nil, fmt.Errorf("rule name is required")
+	}
+	return rule, requires, controls, nil
+}
+
+// convertPolicyCondition converts an as3 policy condition, i.e.
+//
+//	{"type": "httpUri", "event": "request", "path": {"operand": "starts-with", "values": ["/api"]}}
+//
+// to
+//
+//	{"httpUri": true, "request": true, "path": true, "startsWith": true, "values": ["/api"], "caseInsensitive": true}
+func (cc *ConvertContext) convertPolicyCondition(obj map[string]interface{}) (map[string]interface{}, []string, error) {
+	t, ok := obj["type"].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("condition type is required")
+	}
+
+	event := "request"
+	requires := []string{}
+	selectors := []string{}
+	condition := map[string]interface{}{
+		t: true,
+	}
+	switch t {
+	case "httpUri":
+		requires = append(requires, "http")
+		selectors = []string{"all", "path", "queryString", "host", "port", "scheme", "extension", "pathSegment", "queryParameter"}
+	case "httpHeader", "httpCookie":
+		requires = append(requires, "http")
+		selectors = []string{"all"}
+		if n, ok := obj["name"].(string); ok {
+			condition["tmName"] = n
+		} else {
+			return nil, nil, fmt.Errorf("%s condition requires name", t)
+		}
+	case "httpMethod":
+		requires = append(requires, "http")
+		selectors = []string{"all"}
+	case "sslExtension":
+		requires = append(requires, "client-ssl")
+		event = "ssl-client-hello"
+		selectors = []string{"serverName", "npn", "alpn"}
+	case "tcp":
+		requires = append(requires, "tcp")
+		selectors = []string{"address", "port"}
+	default:
+		return nil, nil, fmt.Errorf("unsupported policy condition type: %s", t)
+	}
+
+	if e, ok := obj["event"].(string); ok {
+		event = e
+	}
+	condition[camelCase(event)] = true
+
+	found := false
+	for _, sel := range selectors {
+		v, f := obj[sel]
+		if !f {
+			continue
+		}
+		if found {
+			return nil, nil, fmt.Errorf("only one of %v is allowed in %s condition", selectors, t)
+		}
+		found = true
+		compare, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s of %s condition should be an object", sel, t)
+		}
+		condition[sel] = true
+		operand := "equals"
+		if t == "tcp" && sel == "address" {
+			// the addresses are networks, i.e. 10.0.0.0/8, matched by default.
+			operand = "match"
+		}
+		if err := cc.convertPolicyCompare(compare, operand, condition); err != nil {
+			return nil, nil, fmt.Errorf("%s of %s condition: %s", sel, t, err.Error())
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("one of %v is required in %s condition", selectors, t)
+	}
+	if index, f := obj["index"]; f {
+		condition["index"] = index
+	}
+	return condition, requires, nil
+}
+
+// convertPolicyCompare sets the operand, defaultOperand if not given, and the values of an as3 comparison to the condition.
+func (cc *ConvertContext) convertPolicyCompare(obj map[string]interface{}, defaultOperand string, condition map[string]interface{}) error {
+	operand, ok := obj["operand"].(string)
+	if !ok {
+		operand = defaultOperand
+	}
+	if strings.HasPrefix(operand, "does-not-") {
+		condition["not"] = true
+	}
+	switch operand {
+	case "equals", "does-not-equal":
+		condition["equals"] = true
+	case "starts-with", "does-not-start-with":
+		condition["startsWith"] = true
+	case "ends-with", "does-not-end-with":
+		condition["endsWith"] = true
+	case "contains", "does-not-contain":
+		condition["contains"] = true
+	case "exists", "does-not-exist":
+		condition["exists"] = true
+	case "match", "does-not-match":
+		condition["match"] = true
+	case "less", "greater", "less-or-equal", "greater-or-equal":
+		condition[camelCase(operand)] = true
+	default:
+		return fmt.Errorf("unsupported operand: %s", operand)
+	}
+
+	if values, f := obj["values"]; f {
+		condition["values"] = values
+	}
+	if dg, f := obj["datagroup"]; f {
+		condition["datagroup"] = refers(dg)
+	}
+	for _, o := range []string{"equals", "startsWith", "endsWith", "contains"} {
+		if _, f := condition[o]; !f {
+			continue
+		}
+		// f5-appsvcs: strings are compared case-insensitively unless caseSensitive.
+		if cs, ok := obj["caseSensitive"].(bool); !ok || !cs {
+			condition["caseInsensitive"] = true
+		}
+	}
+	return nil
+}
+
+// convertPolicyAction converts an as3 policy action, i.e.
+//
+//	{"type": "forward", "event": "request", "select": {"pool": {"use": "web_pool"}}}
+//
+// to
+//
+//	{"forward": true, "request": true, "select": true, "pool": "web_pool"}
+//
+// it returns what the action requires and controls as well.
+func (cc *ConvertContext) convertPolicyAction(obj map[string]interface{}) (map[string]interface{}, []string, []string, error) {
+	t, ok := obj["type"].(string)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("action type is required")
+	}
+
+	event := "request"
+	requires, controls := []string{}, []string{}
+	action := map[string]interface{}{}
+	switch t {
+	case "forward":
+		controls = append(controls, "forwarding")
+		action["forward"] = true
+		action["select"] = true
+		sel, ok := obj["select"].(map[string]interface{})
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("forward action requires select")
+		}
+		found := false
+		for _, target := range []string{"pool", "service", "node", "snat", "snatpool"} {
+			if v, f := sel[target]; f {
+				found = true
+				if target == "service" {
+					action["virtual"] = refers(v)
+				} else {
+					action[target] = refers(v)
+				}
+			}
+		}
+		if !found {
+			return nil, nil, nil, fmt.Errorf("forward action requires one of pool, service, node, snat or snatpool")
+		}
+	case "drop":
+		controls = append(controls, "forwarding")
+		action["drop"] = true
+	case "httpRedirect":
+		requires = append(requires, "http")
+		controls = append(controls, "forwarding")
+		action["httpReply"] = true
+		action["redirect"] = true
+		location, ok := obj["location"].(string)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("httpRedirect action requires location")
+		}
+		action["location"] = location
+		if code, f := obj["code"]; f {
+			action["code"] = code
+		}
+	case "httpHeader", "httpCookie":
+		requires = append(requires, "http")
+		action[t] = true
+		found := false
+		for _, op := range []string{"insert", "replace", "remove"} {
+			v, f := obj[op]
+			if !f {
+				continue
+			}
+			found = true
+			opobj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("%s of %s action should be an object", op, t)
+			}
+			action[op] = true
+			if n, f := opobj["name"]; f {
+				action["tmName"] = n
+			}
+			if value, f := opobj["value"]; f {
+				action["value"] = value
+			}
+		}
+		if !found {
+			return nil, nil, nil, fmt.Errorf("%s action requires one of insert, replace or remove", t)
+		}
+	case "httpUri":
+		requires = append(requires, "http")
+		action[t] = true
+		replace, ok := obj["replace"].(map[string]interface{})
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("httpUri action requires replace")
+		}
+		action["replace"] = true
+		for _, f := range []string{"value", "path", "queryString"} {
+			if v, found := replace[f]; found {
+				action[f] = v
+			}
+		}
+	case "log":
+		action["log"] = true
+		action["write"] = true
+		if write, ok := obj["write"].(map[string]interface{}); ok {
+			for k, v := range write {
+				action[k] = v
+			}
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported policy action type: %s", t)
+	}
+
+	if e, ok := obj["event"].(string); ok {
+		event = e
+	}
+	action[camelCase(event)] = true
+	return action, requires, controls, nil
+}
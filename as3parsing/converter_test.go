@@ -0,0 +1,731 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tenants builds a declaration of n tenants, each with two applications, and an invalid snat in the last one.
+func tenants(n int) map[string]interface{} {
+	ts := []string{}
+	for i := 0; i < n; i++ {
+		ts = append(ts, fmt.Sprintf(`"T%d": {"class": "Tenant",
+			"A": {"class": "Application",
+				"vs": {"class": "Service_HTTP", "virtualAddresses": ["10.0.%d.1"], "pool": "web"},
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.%d.1"]}]}},
+			"B": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.2.%d.1"], "virtualPort": 80, "snat": "%s"}}}`,
+			i, i, i, i, map[bool]string{true: "some", false: "auto"}[i == n-1]))
+	}
+	body := map[string]interface{}{}
+	decl := `{"class": "AS3", "declaration": {"class": "ADC", "schemaVersion": "3.0.0", ` + strings.Join(ts, ", ") + `}}`
+	if err := json.Unmarshal([]byte(decl), &body); err != nil {
+		panic(err)
+	}
+	return body
+}
+
+func TestConvertConcurrently(t *testing.T) {
+	body := tenants(8)
+	sequential, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, werr := sequential.ParseAS3(context.TODO(), body)
+	if werr == nil || len(want) != 8 {
+		t.Fatalf("want the 8 tenants and the diagnostics of the invalid snat, got %d tenants, error %v", len(want), werr)
+	}
+	for _, n := range []int{2, 4, 16} {
+		t.Run(fmt.Sprintf("concurrency %d", n), func(t *testing.T) {
+			p, err := NewParser(WithConcurrency(n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.ParseAS3(context.TODO(), body)
+			if plan := DiffRest(want, got); len(plan) != 0 {
+				t.Errorf("different from the sequential:\n%s", plan)
+			}
+			if !reflect.DeepEqual(err, werr) {
+				t.Errorf("got diagnostics %v, want %v", err, werr)
+			}
+		})
+	}
+}
+
+func TestConvertConcurrentlyCancelled(t *testing.T) {
+	p, err := NewParser(WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	objsrc := map[string]interface{}{
+		"T": map[string]interface{}{
+			"A": map[string]interface{}{"ltm/pool/web": map[string]interface{}{"name": "web"}},
+			"B": map[string]interface{}{"ltm/pool/web": map[string]interface{}{"name": "web"}},
+		},
+	}
+	objdst := map[string]interface{}{}
+	if err := newConvertContext(ctx, p).convertConcurrently(objsrc, objdst); err != nil {
+		t.Errorf("got error %s, the caller takes the context error", err)
+	}
+	for fn, folder := range objdst["T"].(map[string]interface{}) {
+		if len(folder.(map[string]interface{})) != 0 {
+			t.Errorf("folder %s is converted after cancelled: %v", fn, folder)
+		}
+	}
+
+	if _, err := p.ParseAS3(ctx, tenants(2)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %s", err, context.Canceled)
+	}
+}
+
+// parseApps parses the applications of tenant T with the default parser.
+func parseApps(t *testing.T, apps string) (map[string]interface{}, error) {
+	t.Helper()
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.ParseAS3(context.TODO(), adc(apps))
+}
+
+// restObject returns the REST object of key in the folder path "partition/folder", nil if not found.
+func restObject(restobjs map[string]interface{}, path, key string) map[string]interface{} {
+	pf := strings.SplitN(path, "/", 2)
+	partition, _ := restobjs[pf[0]].(map[string]interface{})
+	folder, _ := partition[pf[1]].(map[string]interface{})
+	obj, _ := folder[key].(map[string]interface{})
+	return obj
+}
+
+// mismatch compares the properties in want to got by their JSON, it returns the first one different.
+func mismatch(got, want map[string]interface{}) string {
+	keys := []string{}
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g, _ := json.Marshal(got[k])
+		w, _ := json.Marshal(want[k])
+		if string(g) != string(w) {
+			return fmt.Sprintf("%s: got %s, want %s", k, g, w)
+		}
+	}
+	return ""
+}
+
+// diagnostics formats the diagnostics in err as "pointer code" lines.
+func diagnostics(err error) string {
+	ds, ok := err.(Diagnostics)
+	if !ok {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	lines := []string{}
+	for _, d := range ds {
+		lines = append(lines, d.Pointer+" "+d.Code)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestConvertPoolMember(t *testing.T) {
+	cases := []struct {
+		name    string
+		members string
+		// want are the expected properties of the ltm/pool members.
+		want []map[string]interface{}
+		// nodes are the expected ltm/node objects by the folder path.
+		nodes map[string]map[string]map[string]interface{}
+		// diagnostics are the expected "pointer code" lines.
+		diagnostics []string
+	}{
+		{
+			name:    "serverAddresses",
+			members: `[{"servicePort": 80, "serverAddresses": ["10.1.0.1", "10.1.0.2"], "ratio": 2, "connectionLimit": 100}]`,
+			want: []map[string]interface{}{
+				{"name": "10.1.0.1:80", "address": "10.1.0.1", "ratio": 2, "connectionLimit": 100, "session": "user-enabled", "state": "user-up"},
+				{"name": "10.1.0.2:80", "address": "10.1.0.2", "ratio": 2, "connectionLimit": 100, "session": "user-enabled", "state": "user-up"},
+			},
+			nodes: map[string]map[string]map[string]interface{}{
+				"T/": {
+					"ltm/node/10.1.0.1": {"name": "10.1.0.1", "address": "10.1.0.1"},
+					"ltm/node/10.1.0.2": {"name": "10.1.0.2", "address": "10.1.0.2"},
+				},
+			},
+		},
+		{
+			name:    "servers",
+			members: `[{"servicePort": 8080, "servers": [{"name": "n1", "address": "10.1.0.3"}], "adminState": "disable"}]`,
+			want: []map[string]interface{}{
+				{"name": "n1:8080", "address": "10.1.0.3", "session": "user-disabled", "state": "user-up"},
+			},
+			nodes: map[string]map[string]map[string]interface{}{
+				"T/": {"ltm/node/n1": {"name": "n1", "address": "10.1.0.3"}},
+			},
+		},
+		{
+			name:    "shareNodes",
+			members: `[{"servicePort": 443, "serverAddresses": ["10.1.0.4"], "shareNodes": true, "adminState": "offline"}]`,
+			want: []map[string]interface{}{
+				{"name": "/Common/10.1.0.4:443", "address": "10.1.0.4", "session": "user-disabled", "state": "user-down"},
+			},
+			nodes: map[string]map[string]map[string]interface{}{
+				"Common/": {"ltm/node/10.1.0.4": {"name": "10.1.0.4", "address": "10.1.0.4", "partition": "Common"}},
+				"T/":      {"ltm/node/10.1.0.4": nil},
+			},
+		},
+		{
+			name:    "ipv6 servicePort",
+			members: `[{"servicePort": 80, "serverAddresses": ["2001::1"]}]`,
+			want: []map[string]interface{}{
+				{"name": "2001::1.80", "address": "2001::1"},
+			},
+		},
+		{
+			name: "fqdn",
+			members: `[{"servicePort": 80, "addressDiscovery": "fqdn", "hostname": "www.example.com",
+				"autoPopulate": true, "queryInterval": 0, "addressFamily": "IPv6", "downInterval": 10}]`,
+			want: []map[string]interface{}{
+				{"name": "www.example.com:80", "fqdn": map[string]interface{}{"autopopulate": "enabled", "tmName": "www.example.com"}},
+			},
+			nodes: map[string]map[string]map[string]interface{}{
+				"T/": {"ltm/node/www.example.com": {"name": "www.example.com", "fqdn": map[string]interface{}{
+					"addressFamily": "ipv6", "autopopulate": "enabled", "downInterval": 10, "interval": "ttl", "tmName": "www.example.com"}}},
+			},
+		},
+		{
+			name:    "shared fqdn",
+			members: `[{"servicePort": 443, "addressDiscovery": "fqdn", "hostname": "api.example.com", "shareNodes": true, "queryInterval": 30}]`,
+			want: []map[string]interface{}{
+				{"name": "/Common/api.example.com:443", "fqdn": map[string]interface{}{"autopopulate": "disabled", "tmName": "api.example.com"}},
+			},
+			nodes: map[string]map[string]map[string]interface{}{
+				"Common/": {"ltm/node/api.example.com": {"name": "api.example.com", "partition": "Common", "fqdn": map[string]interface{}{
+					"addressFamily": "ipv4", "autopopulate": "disabled", "downInterval": 5, "interval": 30, "tmName": "api.example.com"}}},
+				"T/": {"ltm/node/api.example.com": nil},
+			},
+		},
+		{
+			name:        "fqdn without hostname",
+			members:     `[{"servicePort": 80, "addressDiscovery": "fqdn"}]`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+		{
+			name:        "invalid fqdn addressFamily",
+			members:     `[{"servicePort": 80, "addressDiscovery": "fqdn", "hostname": "www.example.com", "addressFamily": "ipx"}]`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+		{
+			name:        "no servicePort",
+			members:     `[{"serverAddresses": ["10.1.0.5"]}]`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+		{
+			name:        "invalid serverAddresses",
+			members:     `[{"servicePort": 80, "serverAddresses": "10.1.0.5"}]`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+		{
+			name:        "servers without address",
+			members:     `[{"servicePort": 80, "servers": [{"name": "n2"}]}]`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			restobjs, err := parseApps(t, `"A": {"class": "Application", "web": {"class": "Pool", "members": `+c.members+`}}`)
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Fatalf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			if err != nil {
+				return
+			}
+			members, _ := restObject(restobjs, "T/A", "ltm/pool/web")["members"].([]interface{})
+			if len(members) != len(c.want) {
+				t.Fatalf("got %d members, want %d: %v", len(members), len(c.want), members)
+			}
+			for i, m := range members {
+				if s := mismatch(m.(map[string]interface{}), c.want[i]); s != "" {
+					t.Errorf("member %d %s", i, s)
+				}
+			}
+			for path, nodes := range c.nodes {
+				for k, want := range nodes {
+					got := restObject(restobjs, path, k)
+					if want == nil {
+						if got != nil {
+							t.Errorf("%s %s: got %v, want none", path, k, got)
+						}
+						continue
+					}
+					if s := mismatch(got, want); s != "" || len(got) != len(want) {
+						t.Errorf("%s %s: got %v, want %v", path, k, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConvertPolicy(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		// condition is the expected first condition of the first rule.
+		condition          map[string]interface{}
+		requires, controls []string
+	}{
+		{
+			name:  "tcp address matched by default",
+			rules: `[{"name": "net", "conditions": [{"type": "tcp", "address": {"values": ["10.0.0.0/8"]}}], "actions": [{"type": "drop"}]}]`,
+			condition: map[string]interface{}{
+				"name": "0", "tcp": true, "address": true, "match": true, "request": true, "values": []string{"10.0.0.0/8"},
+			},
+			requires: []string{"tcp"},
+			controls: []string{"forwarding"},
+		},
+		{
+			name:  "tcp address not matched",
+			rules: `[{"name": "net", "conditions": [{"type": "tcp", "address": {"operand": "does-not-match", "values": ["10.0.0.0/8"]}}], "actions": [{"type": "drop"}]}]`,
+			condition: map[string]interface{}{
+				"name": "0", "tcp": true, "address": true, "match": true, "not": true, "request": true, "values": []string{"10.0.0.0/8"},
+			},
+			requires: []string{"tcp"},
+			controls: []string{"forwarding"},
+		},
+		{
+			name:  "tcp port",
+			rules: `[{"name": "port", "conditions": [{"type": "tcp", "port": {"operand": "does-not-equal", "values": [80]}}], "actions": [{"type": "drop"}]}]`,
+			condition: map[string]interface{}{
+				"name": "0", "tcp": true, "port": true, "equals": true, "not": true, "caseInsensitive": true, "request": true, "values": []int{80},
+			},
+			requires: []string{"tcp"},
+			controls: []string{"forwarding"},
+		},
+		{
+			name: "sorted requires and controls",
+			rules: `[
+				{"name": "sni", "conditions": [{"type": "sslExtension", "event": "ssl-client-hello", "serverName": {"operand": "ends-with", "values": [".com"]}}],
+					"actions": [{"type": "forward", "event": "ssl-client-hello", "select": {"pool": {"use": "web"}}}]},
+				{"name": "hdr", "conditions": [{"type": "httpHeader", "name": "X", "all": {"operand": "contains", "values": ["a"]}}],
+					"actions": [{"type": "httpHeader", "insert": {"name": "Y", "value": "1"}}]},
+				{"name": "net", "conditions": [{"type": "tcp", "address": {"values": ["10.0.0.0/8"]}}], "actions": [{"type": "drop"}]}
+			]`,
+			condition: map[string]interface{}{
+				"name": "0", "sslExtension": true, "serverName": true, "endsWith": true, "caseInsensitive": true, "sslClientHello": true, "values": []string{".com"},
+			},
+			requires: []string{"client-ssl", "http", "tcp"},
+			controls: []string{"forwarding"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apps := `"A": {"class": "Application",
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"]}]},
+				"pol": {"class": "Endpoint_Policy", "rules": ` + c.rules + `}}`
+			// the requires and controls are collected from maps, parse it more times for a stable order.
+			for i := 0; i < 10; i++ {
+				restobjs, err := parseApps(t, apps)
+				if err != nil {
+					t.Fatal(err)
+				}
+				policy := restObject(restobjs, "T/A", "ltm/policy/pol")
+				if s := mismatch(policy, map[string]interface{}{"requires": c.requires, "controls": c.controls}); s != "" {
+					t.Fatal(s)
+				}
+				rule := policy["rules"].([]interface{})[0].(map[string]interface{})
+				condition := rule["conditions"].([]interface{})[0].(map[string]interface{})
+				if s := mismatch(condition, c.condition); s != "" || len(condition) != len(c.condition) {
+					t.Fatalf("got condition %v, want %v", condition, c.condition)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertMonitor(t *testing.T) {
+	cases := []struct {
+		name    string
+		monitor string
+		// key is the REST object expected, with the properties in want.
+		key         string
+		want        map[string]interface{}
+		diagnostics []string
+	}{
+		{
+			name:    "http adaptive absolute",
+			monitor: `{"class": "Monitor", "monitorType": "http", "send": "GET /\r\n", "receive": "200 OK", "adaptive": true, "adaptiveDivergenceType": "absolute", "adaptiveDivergenceMilliseconds": 300}`,
+			key:     "ltm/monitor/http/m",
+			want: map[string]interface{}{
+				"adaptive": "enabled", "adaptiveDivergenceType": "absolute", "adaptiveDivergenceValue": 300,
+				"send": "GET /\\r\\n", "recv": "200 OK",
+			},
+		},
+		{
+			name:    "tcp adaptive relative",
+			monitor: `{"class": "Monitor", "monitorType": "tcp", "adaptive": true, "adaptiveDivergenceType": "relative", "adaptiveDivergencePercentage": 50, "targetAddress": "10.0.0.1", "targetPort": 8080}`,
+			key:     "ltm/monitor/tcp/m",
+			want: map[string]interface{}{
+				"adaptive": "enabled", "adaptiveDivergenceType": "relative", "adaptiveDivergenceValue": 50, "destination": "10.0.0.1:8080",
+			},
+		},
+		{
+			name:    "udp",
+			monitor: `{"class": "Monitor", "monitorType": "udp", "send": "ping", "receive": "pong"}`,
+			key:     "ltm/monitor/udp/m",
+			want:    map[string]interface{}{"adaptive": "disabled", "send": "ping", "recv": "pong"},
+		},
+		{
+			name:    "icmp",
+			monitor: `{"class": "Monitor", "monitorType": "icmp", "targetAddress": "10.0.0.2"}`,
+			key:     "ltm/monitor/gateway-icmp/m",
+			want:    map[string]interface{}{"destination": "10.0.0.2"},
+		},
+		{
+			name: "mysql",
+			monitor: `{"class": "Monitor", "monitorType": "mysql", "database": "db", "username": "u",
+				"passphrase": {"ciphertext": "cGFzcw==", "protected": "eyJhbGciOiJkaXIiLCJlbmMiOiJub25lIn0="},
+				"send": "select 1", "receive": "1", "receiveColumn": 1, "receiveRow": 1, "count": 5}`,
+			key: "ltm/monitor/mysql/m",
+			want: map[string]interface{}{
+				"database": "db", "username": "u", "password": "pass", "send": "select 1", "recv": "1",
+				"recvColumn": "1", "recvRow": "1", "count": 5,
+			},
+		},
+		{
+			name:    "dns",
+			monitor: `{"class": "Monitor", "monitorType": "dns", "queryName": "example.com", "queryType": "a", "receive": "10.0.0.1"}`,
+			key:     "ltm/monitor/dns/m",
+			want: map[string]interface{}{
+				"qname": "example.com", "qtype": "a", "recv": "10.0.0.1", "acceptRcode": "no-error", "answerContains": "query-type",
+			},
+		},
+		{
+			name:        "invalid adaptiveDivergenceType",
+			monitor:     `{"class": "Monitor", "monitorType": "http", "adaptive": true, "adaptiveDivergenceType": "median"}`,
+			diagnostics: []string{"/T/A/m/adaptiveDivergenceType invalid-value"},
+		},
+		{
+			name:        "negative mysql count",
+			monitor:     `{"class": "Monitor", "monitorType": "mysql", "count": -1}`,
+			diagnostics: []string{"/T/A/m/count invalid-value"},
+		},
+		{
+			name:        "invalid mysql receiveRow",
+			monitor:     `{"class": "Monitor", "monitorType": "mysql", "receiveRow": true}`,
+			diagnostics: []string{"/T/A/m/receiveRow invalid-value"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			restobjs, err := parseApps(t, `"A": {"class": "Application", "m": `+c.monitor+`}`)
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Fatalf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			if err != nil {
+				return
+			}
+			monitor := restObject(restobjs, "T/A", c.key)
+			if monitor == nil {
+				t.Fatalf("%s not found in %v", c.key, restobjs["T"])
+			}
+			if s := mismatch(monitor, c.want); s != "" {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestConvertService(t *testing.T) {
+	cases := []struct {
+		name    string
+		service string
+		// want are the expected properties of the ltm/virtual objects by name.
+		want map[string]map[string]interface{}
+		// addresses are the expected ltm/virtual-address objects by the folder path, nil for none.
+		addresses   map[string]map[string]map[string]interface{}
+		diagnostics []string
+	}{
+		{
+			name:    "ip forwarding",
+			service: `{"class": "Service_Forwarding", "virtualAddresses": ["0.0.0.0/0"], "virtualPort": 0, "forwardingType": "ip"}`,
+			want: map[string]map[string]interface{}{"vs": {
+				"destination": "0.0.0.0:0", "mask": "0.0.0.0", "ipForward": true, "ipProtocol": "any",
+				"profiles":         []interface{}{map[string]interface{}{"name": "/Common/fastL4"}},
+				"translateAddress": "disabled", "translatePort": "disabled", "pool": "",
+			}},
+		},
+		{
+			name:    "L2 forwarding",
+			service: `{"class": "Service_Forwarding", "virtualAddresses": ["10.0.0.0/8"], "virtualPort": 0, "forwardingType": "L2"}`,
+			want: map[string]map[string]interface{}{"vs": {
+				"destination": "10.0.0.0:0", "mask": "255.0.0.0", "l2Forward": true, "ipForward": nil,
+			}},
+		},
+		{
+			name:    "ip forwarding by default",
+			service: `{"class": "Service_Forwarding", "virtualAddresses": ["0.0.0.0/0"], "virtualPort": 0}`,
+			want:    map[string]map[string]interface{}{"vs": {"ipForward": true, "l2Forward": nil}},
+		},
+		{
+			name:        "unknown forwardingType",
+			service:     `{"class": "Service_Forwarding", "virtualAddresses": ["0.0.0.0/0"], "virtualPort": 0, "forwardingType": "L3"}`,
+			diagnostics: []string{"/T/A/vs/forwardingType invalid-value"},
+		},
+		{
+			name:    "network",
+			service: `{"class": "Service_TCP", "virtualAddresses": ["10.0.0.0/24"], "virtualPort": 80}`,
+			want:    map[string]map[string]interface{}{"vs": {"destination": "10.0.0.0:80", "mask": "255.255.255.0", "source": nil}},
+			addresses: map[string]map[string]map[string]interface{}{
+				"T/": {"10.0.0.0": {"address": "10.0.0.0", "mask": "255.255.255.0"}},
+			},
+		},
+		{
+			name:    "ipv6 network",
+			service: `{"class": "Service_TCP", "virtualAddresses": ["2001::1/64"], "virtualPort": 80}`,
+			want:    map[string]map[string]interface{}{"vs": {"destination": "2001::1.80", "mask": "ffff:ffff:ffff:ffff::"}},
+		},
+		{
+			name:    "restricted source",
+			service: `{"class": "Service_TCP", "virtualAddresses": [["10.0.0.1", "192.168.0.0/16"]], "virtualPort": 80}`,
+			want:    map[string]map[string]interface{}{"vs": {"destination": "10.0.0.1:80", "mask": nil, "source": "192.168.0.0/16"}},
+			addresses: map[string]map[string]map[string]interface{}{
+				"T/": {"10.0.0.1": {"address": "10.0.0.1", "mask": nil}},
+			},
+		},
+		{
+			name:    "shareAddresses",
+			service: `{"class": "Service_TCP", "virtualAddresses": ["10.0.0.3"], "virtualPort": 80, "shareAddresses": true}`,
+			want:    map[string]map[string]interface{}{"vs": {"destination": "/Common/10.0.0.3:80"}},
+			addresses: map[string]map[string]map[string]interface{}{
+				"Common/": {"10.0.0.3": {"address": "10.0.0.3", "partition": "Common"}},
+				"T/":      {"10.0.0.3": nil},
+			},
+		},
+		{
+			name: "Service_Address",
+			service: `{"class": "Service_TCP", "virtualAddresses": [{"use": "sa"}], "virtualPort": 80},
+				"sa": {"class": "Service_Address", "virtualAddress": "10.0.0.9", "icmpEcho": "disable"}`,
+			want: map[string]map[string]interface{}{"vs": {"destination": "10.0.0.9:80"}},
+			addresses: map[string]map[string]map[string]interface{}{
+				"T/": {"10.0.0.9": {"address": "10.0.0.9", "icmpEcho": "disabled"}},
+			},
+		},
+		{
+			name:    "multiple addresses",
+			service: `{"class": "Service_TCP", "virtualAddresses": ["10.0.0.4", "10.0.0.5"], "virtualPort": 80, "snat": "self"}`,
+			want: map[string]map[string]interface{}{
+				"vs":    {"destination": "10.0.0.4:80", "sourceAddressTranslation": map[string]interface{}{"type": "snat", "pool": "vs-self"}},
+				"vs-1-": {"destination": "10.0.0.5:80", "sourceAddressTranslation": map[string]interface{}{"type": "snat", "pool": "vs-1--self"}},
+			},
+		},
+		{
+			name:        "invalid prefix length",
+			service:     `{"class": "Service_TCP", "virtualAddresses": ["10.0.0.0/33"], "virtualPort": 80}`,
+			diagnostics: []string{"/T/A/vs/virtualAddresses invalid-value"},
+		},
+		{
+			name:        "invalid source",
+			service:     `{"class": "Service_TCP", "virtualAddresses": [["10.0.0.1", "any"]], "virtualPort": 80}`,
+			diagnostics: []string{"/T/A/vs/virtualAddresses invalid-value"},
+		},
+		{
+			name:    "sctp",
+			service: `{"class": "Service_SCTP", "virtualAddresses": ["10.0.0.3"], "virtualPort": 3868}`,
+			want: map[string]map[string]interface{}{"vs": {
+				"destination": "10.0.0.3:3868", "ipProtocol": "sctp",
+				"profiles":                 []interface{}{map[string]interface{}{"name": "/Common/sctp"}},
+				"sourceAddressTranslation": map[string]interface{}{"type": "automap"},
+			}},
+		},
+		{
+			name:    "sctp profile",
+			service: `{"class": "Service_SCTP", "virtualAddresses": ["10.0.0.3"], "virtualPort": 3868, "profileSCTP": {"bigip": "/Common/sctp-custom"}}`,
+			want: map[string]map[string]interface{}{"vs": {
+				"profiles": []interface{}{map[string]interface{}{"name": "/Common/sctp-custom"}},
+			}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			restobjs, err := parseApps(t, `"A": {"class": "Application", "vs": `+c.service+`,
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"]}]}}`)
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Fatalf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			if err != nil {
+				return
+			}
+			for name, want := range c.want {
+				virtual := restObject(restobjs, "T/A", "ltm/virtual/"+name)
+				if virtual == nil {
+					t.Fatalf("ltm/virtual/%s not found in %v", name, restobjs["T"])
+				}
+				if s := mismatch(virtual, want); s != "" {
+					t.Errorf("%s %s", name, s)
+				}
+			}
+			for path, addresses := range c.addresses {
+				for k, want := range addresses {
+					got := restObject(restobjs, path, "ltm/virtual-address/"+k)
+					if want == nil {
+						if got != nil {
+							t.Errorf("%s %s: got %v, want none", path, k, got)
+						}
+						continue
+					}
+					if s := mismatch(got, want); s != "" {
+						t.Errorf("%s %s %s", path, k, s)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConvertRouteDomain(t *testing.T) {
+	cases := []struct {
+		name string
+		// tenant are the properties of tenant T besides the applications.
+		tenant string
+		apps   string
+		// want are the expected properties of the REST objects by the folder path and key, nil for absent.
+		want map[string]map[string]map[string]interface{}
+		// members are the expected names of the members of pool web.
+		members     []string
+		diagnostics []string
+	}{
+		{
+			name:   "defaultRouteDomain",
+			tenant: `"defaultRouteDomain": 2`,
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80, "pool": "web", "snat": "self"},
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"], "shareNodes": true}, {"servicePort": 80, "serverAddresses": ["10.1.0.2"]}]},
+				"sp": {"class": "SNAT_Pool", "snatAddresses": ["10.2.0.1"]}}`,
+			want: map[string]map[string]map[string]interface{}{
+				"T/A": {
+					"ltm/virtual/vs":       {"destination": "10.0.0.1%2:80"},
+					"ltm/snatpool/vs-self": {"members": []string{"10.0.0.1%2"}},
+					"ltm/snatpool/sp":      {"members": []interface{}{"10.2.0.1%2"}},
+				},
+				"T/": {
+					"ltm/virtual-address/10.0.0.1%2": {"address": "10.0.0.1%2"},
+					"ltm/node/10.1.0.2%2":            {"address": "10.1.0.2%2"},
+				},
+				"Common/": {"ltm/node/10.1.0.1%2": {"address": "10.1.0.1%2"}},
+			},
+			members: []string{"/Common/10.1.0.1%2:80", "10.1.0.2%2:80"},
+		},
+		{
+			name:   "explicit route domain",
+			tenant: `"defaultRouteDomain": 2`,
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1%3"], "virtualPort": 80, "pool": "web"},
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1%3"]}, {"servicePort": 80, "serverAddresses": ["10.1.0.2"], "routeDomain": 4}]}}`,
+			want: map[string]map[string]map[string]interface{}{
+				"T/A": {"ltm/virtual/vs": {"destination": "10.0.0.1%3:80"}},
+				"T/": {
+					"ltm/virtual-address/10.0.0.1%3": {"address": "10.0.0.1%3"},
+					"ltm/virtual-address/10.0.0.1%2": nil,
+				},
+			},
+			members: []string{"10.1.0.1%3:80", "10.1.0.2%4:80"},
+		},
+		{
+			name:   "source in the route domain of the destination",
+			tenant: `"defaultRouteDomain": 2`,
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": [["10.0.0.1", "192.168.0.0/16"]], "virtualPort": 80},
+				"vs2": {"class": "Service_TCP", "virtualAddresses": [["10.0.0.2%3", "192.168.0.0/16"]], "virtualPort": 80}}`,
+			want: map[string]map[string]map[string]interface{}{
+				"T/A": {
+					"ltm/virtual/vs":  {"destination": "10.0.0.1%2:80", "source": "192.168.0.0%2/16"},
+					"ltm/virtual/vs2": {"destination": "10.0.0.2%3:80", "source": "192.168.0.0%3/16"},
+				},
+			},
+		},
+		{
+			name: "route domain 0 is omitted",
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80}}`,
+			want: map[string]map[string]map[string]interface{}{
+				"T/A": {"ltm/virtual/vs": {"destination": "10.0.0.1:80"}},
+				"T/":  {"ltm/virtual-address/10.0.0.1": {"address": "10.0.0.1"}},
+			},
+		},
+		{
+			name:   "source in another route domain",
+			tenant: `"defaultRouteDomain": 2`,
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": [["10.0.0.1", "192.168.0.0%3/16"]], "virtualPort": 80}}`,
+			diagnostics: []string{"/T/A/vs/virtualAddresses invalid-value"},
+		},
+		{
+			name:   "invalid defaultRouteDomain",
+			tenant: `"defaultRouteDomain": 65535`,
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80}}`,
+			diagnostics: []string{"/T/defaultRouteDomain invalid-value"},
+		},
+		{
+			name: "invalid member routeDomain",
+			apps: `"A": {"class": "Application",
+				"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"], "routeDomain": 65535}]}}`,
+			diagnostics: []string{"/T/A/web/members invalid-value"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apps := c.apps
+			if c.tenant != "" {
+				apps = c.tenant + ", " + apps
+			}
+			restobjs, err := parseApps(t, apps)
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Fatalf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			if err != nil {
+				return
+			}
+			for path, objs := range c.want {
+				for k, want := range objs {
+					got := restObject(restobjs, path, k)
+					if want == nil {
+						if got != nil {
+							t.Errorf("%s %s: got %v, want none", path, k, got)
+						}
+						continue
+					}
+					if got == nil {
+						t.Errorf("%s %s not found", path, k)
+						continue
+					}
+					if s := mismatch(got, want); s != "" {
+						t.Errorf("%s %s %s", path, k, s)
+					}
+				}
+			}
+			if c.members != nil {
+				names := []string{}
+				members, _ := restObject(restobjs, "T/A", "ltm/pool/web")["members"].([]interface{})
+				for _, m := range members {
+					names = append(names, m.(map[string]interface{})["name"].(string))
+				}
+				if !reflect.DeepEqual(names, c.members) {
+					t.Errorf("pool members: got %v, want %v", names, c.members)
+				}
+			}
+		})
+	}
+}
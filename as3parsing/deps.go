@@ -0,0 +1,342 @@
+package as3parsing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ResourceID identifies one REST resource of the ParseAS3 output, i.e. partition "T", folder "A", key "ltm/pool/web".
+type ResourceID struct {
+	Partition string `json:"partition"`
+	Folder    string `json:"folder"`
+	Key       string `json:"key"`
+}
+
+func (r ResourceID) String() string {
+	if r.Folder == "" {
+		return fmt.Sprintf("/%s/%s", r.Partition, r.Key)
+	}
+	return fmt.Sprintf("/%s/%s/%s", r.Partition, r.Folder, r.Key)
+}
+
+// Dependency tells From refers to To, so To is created before and deleted after From.
+type Dependency struct {
+	From ResourceID `json:"from"`
+	To   ResourceID `json:"to"`
+}
+
+// Graph is the dependencies among the REST resources, the ones referred out of the
+// resources, i.e. /Common/http, are not included.
+type Graph struct {
+	Resources    []ResourceID `json:"resources"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// dependencyFields tells the fields referring to other resources, per kind:
+//
+//	kind prefix -> field -> kind prefixes of the referred resources
+var dependencyFields = map[string]map[string][]string{
+	"ltm/virtual/": {
+		"destination":         {"ltm/virtual-address/"},
+		"pool":                {"ltm/pool/", "ltm/snatpool/"},
+		"rules":               {"ltm/rule/"},
+		"profiles":            {"ltm/profile/"},
+		"persist":             {"ltm/persistence/"},
+		"fallbackPersistence": {"ltm/persistence/"},
+		"policies":            {"ltm/policy/"},
+	},
+	"ltm/pool/": {
+		"members": {"ltm/node/"},
+		"monitor": {"ltm/monitor/"},
+	},
+	"ltm/profile/": {
+		"cert":         {"sys/file/ssl-cert/"},
+		"key":          {"sys/file/ssl-key/"},
+		"chain":        {"sys/file/ssl-cert/"},
+		"caFile":       {"sys/file/ssl-cert/"},
+		"crlFile":      {"sys/file/ssl-crl/"},
+		"defaultsFrom": {"ltm/profile/"},
+	},
+	"ltm/monitor/": {
+		"cert":         {"sys/file/ssl-cert/"},
+		"key":          {"sys/file/ssl-key/"},
+		"sslProfile":   {"ltm/profile/"},
+		"run":          {"sys/file/external-monitor/"},
+		"defaultsFrom": {"ltm/monitor/"},
+	},
+	"ltm/persistence/": {
+		"rule":         {"ltm/rule/"},
+		"defaultsFrom": {"ltm/persistence/"},
+	},
+	"ltm/policy/": {
+		"pool":     {"ltm/pool/"},
+		"snatpool": {"ltm/snatpool/"},
+		"virtual":  {"ltm/virtual/"},
+	},
+	"ltm/data-group/": {
+		"externalFileName": {"sys/file/data-group/"},
+	},
+	"sys/file/": {
+		"sourcePath": {"shared/file-transfer/uploads/"},
+	},
+}
+
+// DependencyGraph derives the dependencies from the references in the REST objects
+// in the format ParseAS3 returns, i.e. pools, profiles and rules of virtuals.
+func DependencyGraph(restobjs map[string]interface{}) *Graph {
+	flat := flattenRest(restobjs)
+	g := &Graph{Resources: []ResourceID{}, Dependencies: []Dependency{}}
+	folders := map[[2]string][]ResourceID{}
+	for k := range flat {
+		r := ResourceID{Partition: k[0], Folder: k[1], Key: k[2]}
+		g.Resources = append(g.Resources, r)
+		folders[[2]string{k[0], k[1]}] = append(folders[[2]string{k[0], k[1]}], r)
+	}
+	sortResources(g.Resources)
+
+	for _, r := range g.Resources {
+		for prefix, fields := range dependencyFields {
+			if !strings.HasPrefix(r.Key, prefix) {
+				continue
+			}
+			found := map[ResourceID]bool{}
+			walkReferences(normalize(flat[[3]string{r.Partition, r.Folder, r.Key}]), fields, func(kinds []string, ref string) {
+				if to, f := locateResource(folders, r, kinds, ref); f && to != r && !found[to] {
+					found[to] = true
+					g.Dependencies = append(g.Dependencies, Dependency{From: r, To: to})
+				}
+			})
+		}
+	}
+	sort.Slice(g.Dependencies, func(i, j int) bool {
+		a, b := g.Dependencies[i], g.Dependencies[j]
+		if a.From != b.From {
+			return a.From.String() < b.From.String()
+		}
+		return a.To.String() < b.To.String()
+	})
+	return g
+}
+
+// CreateOrder sorts the resources topologically, the referred ones come first.
+func (g *Graph) CreateOrder() ([]ResourceID, error) {
+	refers := map[ResourceID][]ResourceID{}
+	for _, d := range g.Dependencies {
+		refers[d.From] = append(refers[d.From], d.To)
+	}
+	ordered := []ResourceID{}
+	// 0: not visited, 1: visiting, 2: done
+	states := map[ResourceID]int{}
+	var visit func(r ResourceID, path []ResourceID) error
+	visit = func(r ResourceID, path []ResourceID) error {
+		switch states[r] {
+		case 1:
+			cycle := []string{}
+			for _, p := range append(path, r) {
+				cycle = append(cycle, p.String())
+			}
+			return fmt.Errorf("circular dependency: %s", strings.Join(cycle, " -> "))
+		case 2:
+			return nil
+		}
+		states[r] = 1
+		tos := refers[r]
+		sortResources(tos)
+		for _, to := range tos {
+			if err := visit(to, append(path, r)); err != nil {
+				return err
+			}
+		}
+		states[r] = 2
+		ordered = append(ordered, r)
+		return nil
+	}
+	for _, r := range g.Resources {
+		if err := visit(r, []ResourceID{}); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// DeleteOrder is the reverse of CreateOrder, the referring resources come first.
+func (g *Graph) DeleteOrder() ([]ResourceID, error) {
+	ordered, err := g.CreateOrder()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+	return ordered, nil
+}
+
+// Ordered sorts the plan for deployment: creates and updates in the create order of newobjs,
+// then deletes in the delete order of oldobjs, so that nothing is deleted while still referred.
+func (pl Plan) Ordered(oldobjs, newobjs map[string]interface{}) (Plan, error) {
+	createOrder, err := DependencyGraph(newobjs).CreateOrder()
+	if err != nil {
+		return nil, err
+	}
+	deleteOrder, err := DependencyGraph(oldobjs).DeleteOrder()
+	if err != nil {
+		return nil, err
+	}
+	ops := map[ResourceID]Operation{}
+	for _, op := range pl {
+		ops[ResourceID{Partition: op.Partition, Folder: op.Folder, Key: op.Key}] = op
+	}
+	ordered := Plan{}
+	for _, r := range createOrder {
+		if op, f := ops[r]; f && op.Op != OpDelete {
+			ordered = append(ordered, op)
+		}
+	}
+	for _, r := range deleteOrder {
+		if op, f := ops[r]; f && op.Op == OpDelete {
+			ordered = append(ordered, op)
+		}
+	}
+	return ordered, nil
+}
+
+// walkReferences calls found with the referred names in the fields of obj, at any depth.
+func walkReferences(obj interface{}, fields map[string][]string, found func(kinds []string, ref string)) {
+	if obj == nil {
+		return
+	}
+	switch reflect.TypeOf(obj).Kind().String() {
+	case "map":
+		for k, v := range obj.(map[string]interface{}) {
+			if kinds, f := fields[k]; f {
+				for _, ref := range referredNames(k, v) {
+					found(kinds, ref)
+				}
+			}
+			walkReferences(v, fields, found)
+		}
+	case "slice":
+		for _, v := range obj.([]interface{}) {
+			walkReferences(v, fields, found)
+		}
+	}
+}
+
+// referredNames returns the names referred by the field value, in the forms of:
+//
+//	"name", ["name"], [{"name": "name"}]
+//
+// and the field specific ones, i.e. "min 1 of /T/A/m1 /T/A/m2" for pool monitor.
+func referredNames(field string, v interface{}) []string {
+	names := []string{}
+	switch field {
+	case "destination":
+		if s, ok := v.(string); ok {
+			names = append(names, stripPort(s))
+		}
+		return names
+	case "monitor":
+		if s, ok := v.(string); ok {
+			for _, t := range strings.Fields(s) {
+				switch t {
+				case "min", "of", "and":
+				default:
+					if strings.Trim(t, "0123456789") != "" {
+						names = append(names, t)
+					}
+				}
+			}
+		}
+		return names
+	case "members":
+		for _, ref := range referredNames("", v) {
+			names = append(names, stripPort(ref))
+		}
+		return names
+	case "sourcePath":
+		if s, ok := v.(string); ok {
+			names = append(names, s[strings.LastIndex(s, "/")+1:])
+		}
+		return names
+	}
+	switch t := v.(type) {
+	case string:
+		names = append(names, t)
+	case []interface{}:
+		for _, item := range t {
+			switch i := item.(type) {
+			case string:
+				names = append(names, i)
+			case map[string]interface{}:
+				if n, ok := i["name"].(string); ok {
+					names = append(names, n)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// stripPort returns the address part of "addr:port", or "addr.port" for IPv6.
+func stripPort(dest string) string {
+	sep := ":"
+	if strings.Count(dest, ":") > 1 {
+		sep = "."
+	}
+	if i := strings.LastIndex(dest, sep); i > 0 {
+		return dest[:i]
+	}
+	return dest
+}
+
+// locateResource finds the resource of kinds named by ref, referred from r.
+// A relative name is looked up in r's folder, r's partition, then /Common and /Common/Shared.
+func locateResource(folders map[[2]string][]ResourceID, r ResourceID, kinds []string, ref string) (ResourceID, bool) {
+	candidates := [][2]string{}
+	name := ref
+	if strings.HasPrefix(ref, "/") {
+		segs := strings.Split(ref[1:], "/")
+		name = segs[len(segs)-1]
+		switch len(segs) {
+		case 2:
+			candidates = append(candidates, [2]string{segs[0], ""})
+		case 3:
+			candidates = append(candidates, [2]string{segs[0], segs[1]})
+		}
+	} else {
+		candidates = append(candidates,
+			[2]string{r.Partition, r.Folder},
+			[2]string{r.Partition, ""},
+			[2]string{"Common", ""},
+			[2]string{"Common", "Shared"},
+		)
+	}
+	for _, c := range candidates {
+		for _, res := range folders[c] {
+			if !strings.HasSuffix(res.Key, "/"+name) {
+				continue
+			}
+			for _, kind := range kinds {
+				if strings.HasPrefix(res.Key, kind) {
+					return res, true
+				}
+			}
+		}
+	}
+	return ResourceID{}, false
+}
+
+func sortResources(rs []ResourceID) {
+	sort.Slice(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		if a.Folder != b.Folder {
+			return a.Folder < b.Folder
+		}
+		return a.Key < b.Key
+	})
+}
@@ -0,0 +1,127 @@
+package as3parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDependencyOrders(t *testing.T) {
+	cases := []struct {
+		name    string
+		objs    map[string]interface{}
+		creates []string
+		// cycle is the error of both orders if set.
+		cycle string
+	}{
+		{
+			name: "virtual, pool, monitor, node and virtual address",
+			objs: map[string]interface{}{
+				"T": map[string]interface{}{
+					"": map[string]interface{}{
+						"ltm/node/10.1.0.1":            map[string]interface{}{"name": "10.1.0.1"},
+						"ltm/virtual-address/10.0.0.1": map[string]interface{}{"name": "10.0.0.1"},
+					},
+					"A": map[string]interface{}{
+						"ltm/virtual/vs": map[string]interface{}{
+							"destination": "/T/10.0.0.1:80",
+							"pool":        "/T/A/web",
+							"profiles":    []interface{}{map[string]interface{}{"name": "/Common/http"}},
+						},
+						"ltm/pool/web": map[string]interface{}{
+							"members": []interface{}{map[string]interface{}{"name": "/T/10.1.0.1:80"}},
+							"monitor": "min 1 of /T/A/m /Common/http",
+						},
+						"ltm/monitor/http/m": map[string]interface{}{"name": "m"},
+					},
+				},
+			},
+			creates: []string{
+				"/T/ltm/node/10.1.0.1",
+				"/T/ltm/virtual-address/10.0.0.1",
+				"/T/A/ltm/monitor/http/m",
+				"/T/A/ltm/pool/web",
+				"/T/A/ltm/virtual/vs",
+			},
+		},
+		{
+			name: "files before certificates before profiles",
+			objs: map[string]interface{}{
+				"T": map[string]interface{}{
+					"A": map[string]interface{}{
+						"ltm/profile/client-ssl/tls":                map[string]interface{}{"cert": "/T/A/c.crt", "key": "/T/A/c.key"},
+						"sys/file/ssl-cert/c.crt":                   map[string]interface{}{"sourcePath": "file:/var/config/rest/downloads/_T__A__c.crt"},
+						"sys/file/ssl-key/c.key":                    map[string]interface{}{"sourcePath": "file:/var/config/rest/downloads/_T__A__c.key"},
+						"shared/file-transfer/uploads/_T__A__c.crt": map[string]interface{}{"content": "crt"},
+						"shared/file-transfer/uploads/_T__A__c.key": map[string]interface{}{"content": "key"},
+					},
+				},
+			},
+			creates: []string{
+				"/T/A/shared/file-transfer/uploads/_T__A__c.crt",
+				"/T/A/sys/file/ssl-cert/c.crt",
+				"/T/A/shared/file-transfer/uploads/_T__A__c.key",
+				"/T/A/sys/file/ssl-key/c.key",
+				"/T/A/ltm/profile/client-ssl/tls",
+			},
+		},
+		{
+			name: "policy forwarding to the virtual using it",
+			objs: map[string]interface{}{
+				"T": map[string]interface{}{
+					"A": map[string]interface{}{
+						"ltm/virtual/vs": map[string]interface{}{
+							"policies": []interface{}{map[string]interface{}{"name": "/T/A/pol"}},
+						},
+						"ltm/policy/pol": map[string]interface{}{
+							"rules": []interface{}{map[string]interface{}{
+								"actions": []interface{}{map[string]interface{}{"forward": true, "virtual": "/T/A/vs"}},
+							}},
+						},
+					},
+				},
+			},
+			cycle: "circular dependency: /T/A/ltm/policy/pol -> /T/A/ltm/virtual/vs -> /T/A/ltm/policy/pol",
+		},
+	}
+
+	names := func(rs []ResourceID) string {
+		ss := []string{}
+		for _, r := range rs {
+			ss = append(ss, r.String())
+		}
+		return strings.Join(ss, "\n")
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := DependencyGraph(c.objs)
+			creates, err := g.CreateOrder()
+			if c.cycle != "" {
+				if err == nil || err.Error() != c.cycle {
+					t.Errorf("CreateOrder: got error %v, want %s", err, c.cycle)
+				}
+				if _, err := g.DeleteOrder(); err == nil || err.Error() != c.cycle {
+					t.Errorf("DeleteOrder: got error %v, want %s", err, c.cycle)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateOrder: %s", err)
+			}
+			if got, want := names(creates), strings.Join(c.creates, "\n"); got != want {
+				t.Errorf("CreateOrder got:\n%s\nwant:\n%s", got, want)
+			}
+
+			deletes, err := g.DeleteOrder()
+			if err != nil {
+				t.Fatalf("DeleteOrder: %s", err)
+			}
+			want := []string{}
+			for i := len(c.creates) - 1; i >= 0; i-- {
+				want = append(want, c.creates[i])
+			}
+			if got := names(deletes); got != strings.Join(want, "\n") {
+				t.Errorf("DeleteOrder got:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package as3parsing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	CodeInvalidValue      = "invalid-value"
+	CodeMalformed         = "malformed"
+	CodeUnknownClass      = "unknown-class"
+	CodeUnsupported       = "unsupported"
+	CodeDanglingReference = "dangling-reference"
+)
+
+// Diagnostic describes one problem found in the declaration.
+type Diagnostic struct {
+	// Pointer is the json pointer into the declaration, i.e. /Tenant/App/vs/virtualPort
+	Pointer  string `json:"pointer"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Pointer == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s: %s", d.Pointer, d.Message)
+}
+
+// Diagnostics holds all the problems found in one pass, it's returned as the error of ParseAS3.
+//
+//	var ds as3parsing.Diagnostics
+//	if errors.As(err, &ds) { ... }
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	msgs := []string{}
+	for _, d := range ds {
+		msgs = append(msgs, d.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// HasErrors tells if any of the diagnostics is of SeverityError.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends err found in the object or property name, prefixing the pointers with "/name".
+// err may be Diagnostics, *diagError or any other error.
+func (ds *Diagnostics) add(name string, err error) {
+	if err == nil {
+		return
+	}
+	prefix := ""
+	if name != "" {
+		prefix = "/" + name
+	}
+	var sub Diagnostics
+	if errors.As(err, &sub) {
+		for _, d := range sub {
+			d.Pointer = prefix + d.Pointer
+			*ds = append(*ds, d)
+		}
+		return
+	}
+	d := Diagnostic{Pointer: prefix, Severity: SeverityError, Code: CodeInvalidValue, Message: err.Error()}
+	var de *diagError
+	if errors.As(err, &de) {
+		d.Code = de.code
+	}
+	*ds = append(*ds, d)
+}
+
+// without returns the diagnostics except the ones in the tenant.
+func (ds Diagnostics) without(tenant string) Diagnostics {
+	rlt := Diagnostics{}
+	for _, d := range ds {
+		if d.Pointer != "/"+tenant && !strings.HasPrefix(d.Pointer, "/"+tenant+"/") {
+			rlt = append(rlt, d)
+		}
+	}
+	return rlt
+}
+
+// err returns the sorted diagnostics, or nil if there is none.
+func (ds Diagnostics) err() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		if ds[i].Pointer != ds[j].Pointer {
+			return ds[i].Pointer < ds[j].Pointer
+		}
+		return ds[i].Message < ds[j].Message
+	})
+	return ds
+}
+
+// diagError is an error with its Diagnostic code.
+type diagError struct {
+	code string
+	err  error
+}
+
+func (e *diagError) Error() string {
+	return e.err.Error()
+}
+
+func (e *diagError) Unwrap() error {
+	return e.err
+}
+
+func newDiagError(code, format string, args ...interface{}) error {
+	return &diagError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// eachProperty calls convert for each property of obj in the order of property names,
+// the problems of all the properties, including the panics of malformed values, are returned as Diagnostics.
+func eachProperty(obj map[string]interface{}, convert func(k string, v interface{}) error) error {
+	keys := []string{}
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ds := Diagnostics{}
+	for _, k := range keys {
+		err := func() (err error) {
+			defer malformed(&err)
+			return convert(k, obj[k])
+		}()
+		ds.add(k, err)
+	}
+	return ds.err()
+}
+
+// malformed turns the panic raised by malformed input into an error.
+// It's only the last-resort guard, the converters check the types of the values they use and report CodeInvalidValue.
+func malformed(err *error) {
+	if r := recover(); r != nil {
+		*err = &diagError{code: CodeMalformed, err: fmt.Errorf("malformed value: %v", r)}
+	}
+}
@@ -0,0 +1,128 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAS3Diagnostics(t *testing.T) {
+	cases := []struct {
+		name string
+		// body is the as3 body, or the applications of tenant T if it doesn't start with '{'.
+		body        string
+		diagnostics []string
+	}{
+		{
+			name:        "non-object declaration",
+			body:        `{"class": "AS3", "declaration": "T"}`,
+			diagnostics: []string{"/declaration malformed"},
+		},
+		{
+			name:        "unknown class",
+			body:        `"A": {"class": "Application", "x": {"class": "Service_Nothing"}}`,
+			diagnostics: []string{"/T/A/x unknown-class"},
+		},
+		{
+			name:        "virtualAddresses not an array",
+			body:        `"A": {"class": "Application", "vs": {"class": "Service_TCP", "virtualAddresses": "10.0.0.1", "virtualPort": 80}}`,
+			diagnostics: []string{"/T/A/vs/virtualAddresses invalid-value"},
+		},
+		{
+			name:        "unknown snat",
+			body:        `"A": {"class": "Application", "vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80, "snat": "any"}}`,
+			diagnostics: []string{"/T/A/vs/snat invalid-value"},
+		},
+		{
+			name:        "iRules not references",
+			body:        `"A": {"class": "Application", "vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80, "iRules": [1]}}`,
+			diagnostics: []string{"/T/A/vs/iRules invalid-value"},
+		},
+		{
+			name:        "minimumMonitors",
+			body:        `"A": {"class": "Application", "web": {"class": "Pool", "monitors": ["http"], "minimumMonitors": "some"}}`,
+			diagnostics: []string{"/T/A/web/minimumMonitors invalid-value"},
+		},
+		{
+			name: "all the problems together",
+			body: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.1"], "virtualPort": 80, "snat": "any", "pool": "nope"},
+				"web": {"class": "Pool", "monitors": ["http"], "minimumMonitors": "some"}},
+				"B": {"class": "Application", "x": {"class": "Service_Nothing"}}`,
+			diagnostics: []string{
+				"/T/A/vs/pool dangling-reference",
+				"/T/A/vs/snat invalid-value",
+				"/T/A/web/minimumMonitors invalid-value",
+				"/T/B/x unknown-class",
+			},
+		},
+	}
+
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if strings.HasPrefix(c.body, "{") {
+				body = map[string]interface{}{}
+				if err := json.Unmarshal([]byte(c.body), &body); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				body = adc(c.body)
+			}
+			_, err := p.ParseAS3(context.TODO(), body)
+			var ds Diagnostics
+			if !errors.As(err, &ds) {
+				t.Fatalf("got error %v, want diagnostics", err)
+			}
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			for _, d := range ds {
+				if d.Severity != SeverityError || d.Message == "" {
+					t.Errorf("got diagnostic %v, want an error with the message", d)
+				}
+			}
+		})
+	}
+}
+
+func TestEachProperty(t *testing.T) {
+	obj := map[string]interface{}{"a": 1, "b": "x", "c": []interface{}{}, "d": map[string]interface{}{"e": 1}}
+	err := eachProperty(obj, func(k string, v interface{}) error {
+		switch k {
+		case "a":
+			return newDiagError(CodeInvalidValue, "a should be a string")
+		case "b":
+			// the last-resort guard of an unchecked assertion.
+			_ = v.(int)
+		case "c":
+			return errors.New("no code")
+		case "d":
+			return eachProperty(v.(map[string]interface{}), func(k string, v interface{}) error {
+				return newDiagError(CodeUnsupported, "%s is not supported", k)
+			})
+		}
+		return nil
+	})
+	want := []string{"/a invalid-value", "/b malformed", "/c invalid-value", "/d/e unsupported"}
+	if got := diagnostics(err); got != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestDiagnosticsWithout(t *testing.T) {
+	ds := Diagnostics{}
+	for _, p := range []string{"/T", "/T/A/vs", "/T2/A/vs", "/Tenant", "/declaration"} {
+		ds = append(ds, Diagnostic{Pointer: p, Code: CodeInvalidValue})
+	}
+	want := []string{"/T2/A/vs invalid-value", "/Tenant invalid-value", "/declaration invalid-value"}
+	if got := diagnostics(ds.without("T")); got != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
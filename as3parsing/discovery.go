@@ -0,0 +1,202 @@
+package as3parsing
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveredMember is one pool member found by a MemberDiscoverer.
+type DiscoveredMember struct {
+	Address string `json:"ip"`
+	// Port is the port of the member, 0 for the servicePort of the as3 pool member.
+	Port int `json:"port,omitempty"`
+}
+
+// MemberDiscoverer finds the members of the as3 pool members of an addressDiscovery other than static and fqdn,
+// i.e. "event" or "consul". pool is the full path of the pool, i.e. /T/A/web, and member is the as3 pool member.
+// The trustCA of member, if given, is the PEM of the CA_Bundle referred.
+// Discover is called from the converting goroutines, it should be safe for concurrent use.
+type MemberDiscoverer interface {
+	Discover(ctx context.Context, pool string, member map[string]interface{}) ([]DiscoveredMember, error)
+}
+
+// WithMemberDiscoverer registers the MemberDiscoverer for the addressDiscovery, i.e. "consul".
+// NewParser registers NewConsulDiscoverer() for "consul" unless given, "event" is supported only with the
+// EventDiscoverer registered, which the caller keeps to set the members.
+func WithMemberDiscoverer(addressDiscovery string, d MemberDiscoverer) ParserOption {
+	return func(p *Parser) {
+		if p.discoverers == nil {
+			p.discoverers = map[string]MemberDiscoverer{}
+		}
+		p.discoverers[addressDiscovery] = d
+	}
+}
+
+// EventDiscoverer serves the members of 'addressDiscovery: event' supplied by the caller,
+// as BIG-IP does with the members posted to /mgmt/shared/service-discovery/task/~T~A~pool/nodes.
+type EventDiscoverer struct {
+	mutex   sync.RWMutex
+	members map[string][]DiscoveredMember
+}
+
+// NewEventDiscoverer creates an EventDiscoverer with no members, to share with WithMemberDiscoverer("event", d).
+func NewEventDiscoverer() *EventDiscoverer {
+	return &EventDiscoverer{members: map[string][]DiscoveredMember{}}
+}
+
+// SetMembers sets the members of the pool, i.e. /T/A/web, nil to remove them.
+func (d *EventDiscoverer) SetMembers(pool string, members []DiscoveredMember) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if members == nil {
+		delete(d.members, pool)
+		return
+	}
+	d.members[pool] = append([]DiscoveredMember{}, members...)
+}
+
+// Discover returns the members set for the pool, none if not set yet.
+func (d *EventDiscoverer) Discover(ctx context.Context, pool string, member map[string]interface{}) ([]DiscoveredMember, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return append([]DiscoveredMember{}, d.members[pool]...), nil
+}
+
+// ConsulDiscoverer reads the members of 'addressDiscovery: consul' from the Consul catalog given by the member's uri,
+// i.e. http://consul:8500/v1/catalog/service/web, or a local file of the same JSON, i.e. file:///etc/consul/web.json.
+// Both /v1/catalog/service and /v1/health/service responses are accepted.
+//
+// encodedToken, trustCA, rejectUnauthorized and addressRealm of the member are honored.
+type ConsulDiscoverer struct {
+	Timeout time.Duration
+}
+
+// NewConsulDiscoverer creates a ConsulDiscoverer with the timeout of 10 seconds.
+func NewConsulDiscoverer() *ConsulDiscoverer {
+	return &ConsulDiscoverer{Timeout: 10 * time.Second}
+}
+
+// consulService is one entry of the catalog, or the flattened entry of /v1/health/service.
+type consulService struct {
+	Address         string            `json:"Address"`
+	TaggedAddresses map[string]string `json:"TaggedAddresses"`
+	ServiceAddress  string            `json:"ServiceAddress"`
+	ServicePort     int               `json:"ServicePort"`
+	Node            json.RawMessage   `json:"Node"`
+	Service         *struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// Discover reads the catalog, the members take the servicePort of the as3 pool member.
+func (d *ConsulDiscoverer) Discover(ctx context.Context, pool string, member map[string]interface{}) ([]DiscoveredMember, error) {
+	uri, ok := member["uri"].(string)
+	if !ok || uri == "" {
+		return nil, fmt.Errorf("uri is required for addressDiscovery consul")
+	}
+	data, err := d.read(ctx, uri, member)
+	if err != nil {
+		return nil, err
+	}
+	var services []consulService
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the consul catalog %s: %s", uri, err.Error())
+	}
+
+	realm, _ := member["addressRealm"].(string)
+	members := []DiscoveredMember{}
+	for _, svc := range services {
+		if svc.Service != nil {
+			// /v1/health/service: {"Node": {...}, "Service": {...}}
+			var node consulService
+			if err := json.Unmarshal(svc.Node, &node); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal the consul node: %s", err.Error())
+			}
+			node.ServiceAddress, node.ServicePort = svc.Service.Address, svc.Service.Port
+			svc = node
+		}
+		addr := svc.ServiceAddress
+		if addr == "" {
+			addr = svc.Address
+		}
+		if realm == "public" {
+			if wan := svc.TaggedAddresses["wan"]; wan != "" {
+				addr = wan
+			}
+		}
+		if addr == "" {
+			continue
+		}
+		members = append(members, DiscoveredMember{Address: addr})
+	}
+	return members, nil
+}
+
+// read reads the catalog from the file or the Consul HTTP API.
+func (d *ConsulDiscoverer) read(ctx context.Context, uri string, member map[string]interface{}) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri %s: %s", uri, err.Error())
+	}
+	switch u.Scheme {
+	case "file":
+		return os.ReadFile(u.Path)
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("not supported uri scheme: %s", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token, ok := member["encodedToken"].(string); ok && token != "" {
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encodedToken: %s", err.Error())
+		}
+		req.Header.Set("X-Consul-Token", strings.TrimSpace(string(decoded)))
+	}
+	reject := true
+	if v, ok := member["rejectUnauthorized"].(bool); ok {
+		reject = v
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: !reject}
+	if ca, ok := member["trustCA"].(string); ok && ca != "" {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(ca)) {
+			return nil, fmt.Errorf("no certificate found in trustCA")
+		}
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: d.Timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("consul catalog %s responds with code %d: %s", uri, resp.StatusCode, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,238 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventDiscoverer(t *testing.T) {
+	d := NewEventDiscoverer()
+	members := []DiscoveredMember{{Address: "10.1.0.1"}, {Address: "10.1.0.2", Port: 8080}}
+	d.SetMembers("/T/A/web", members)
+	// the members set are copied.
+	members[0].Address = "10.1.0.9"
+
+	cases := []struct {
+		pool string
+		want []DiscoveredMember
+	}{
+		{"/T/A/web", []DiscoveredMember{{Address: "10.1.0.1"}, {Address: "10.1.0.2", Port: 8080}}},
+		{"/T/A/other", []DiscoveredMember{}},
+	}
+	for _, c := range cases {
+		got, err := d.Discover(context.TODO(), c.pool, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", c.pool, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.pool, got, c.want)
+		}
+	}
+
+	d.SetMembers("/T/A/web", nil)
+	if got, _ := d.Discover(context.TODO(), "/T/A/web", nil); len(got) != 0 {
+		t.Errorf("got %v after removed", got)
+	}
+}
+
+const (
+	consulCatalog = `[
+		{"Address": "10.0.0.1", "TaggedAddresses": {"wan": "192.168.0.1"}, "ServiceAddress": "", "ServicePort": 80},
+		{"Address": "10.0.0.2", "TaggedAddresses": {"wan": "192.168.0.2"}, "ServiceAddress": "10.1.0.2", "ServicePort": 80}
+	]`
+	consulHealth = `[
+		{"Node": {"Address": "10.0.0.3", "TaggedAddresses": {"wan": "192.168.0.3"}}, "Service": {"Address": "", "Port": 80}},
+		{"Node": {"Address": "10.0.0.4"}, "Service": {"Address": "10.1.0.4", "Port": 80}}
+	]`
+)
+
+func TestConsulDiscoverer(t *testing.T) {
+	token := "secret"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/catalog/service/private" && r.Header.Get("X-Consul-Token") != token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/catalog/service/"):
+			fmt.Fprint(w, consulCatalog)
+		case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+			fmt.Fprint(w, consulHealth)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, consulCatalog)
+	}))
+	defer tlsSrv.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}))
+	file := filepath.Join(t.TempDir(), "web.json")
+	if err := os.WriteFile(file, []byte(consulHealth), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		member map[string]interface{}
+		want   []string
+		// err is the prefix of the error expected.
+		err string
+	}{
+		{
+			name:   "catalog",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/catalog/service/web"},
+			want:   []string{"10.0.0.1", "10.1.0.2"},
+		},
+		{
+			name:   "catalog in public realm",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/catalog/service/web", "addressRealm": "public"},
+			want:   []string{"192.168.0.1", "192.168.0.2"},
+		},
+		{
+			name:   "health",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/health/service/web"},
+			want:   []string{"10.0.0.3", "10.1.0.4"},
+		},
+		{
+			name:   "health in public realm",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/health/service/web", "addressRealm": "public"},
+			want:   []string{"192.168.0.3", "10.1.0.4"},
+		},
+		{
+			name:   "file",
+			member: map[string]interface{}{"uri": "file://" + file},
+			want:   []string{"10.0.0.3", "10.1.0.4"},
+		},
+		{
+			name: "encoded token",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/catalog/service/private",
+				"encodedToken": base64.StdEncoding.EncodeToString([]byte(token + "\n"))},
+			want: []string{"10.0.0.1", "10.1.0.2"},
+		},
+		{
+			name:   "missing token",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/catalog/service/private"},
+			err:    "consul catalog " + srv.URL + "/v1/catalog/service/private responds with code 403",
+		},
+		{
+			name:   "invalid encoded token",
+			member: map[string]interface{}{"uri": srv.URL + "/v1/catalog/service/private", "encodedToken": "%%"},
+			err:    "failed to decode encodedToken",
+		},
+		{
+			name:   "trustCA",
+			member: map[string]interface{}{"uri": tlsSrv.URL + "/v1/catalog/service/web", "trustCA": ca},
+			want:   []string{"10.0.0.1", "10.1.0.2"},
+		},
+		{
+			name:   "untrusted",
+			member: map[string]interface{}{"uri": tlsSrv.URL + "/v1/catalog/service/web"},
+			err:    "Get \"" + tlsSrv.URL,
+		},
+		{
+			name:   "rejectUnauthorized false",
+			member: map[string]interface{}{"uri": tlsSrv.URL + "/v1/catalog/service/web", "rejectUnauthorized": false},
+			want:   []string{"10.0.0.1", "10.1.0.2"},
+		},
+		{
+			name:   "invalid trustCA",
+			member: map[string]interface{}{"uri": tlsSrv.URL + "/v1/catalog/service/web", "trustCA": "none"},
+			err:    "no certificate found in trustCA",
+		},
+		{
+			name:   "no uri",
+			member: map[string]interface{}{},
+			err:    "uri is required for addressDiscovery consul",
+		},
+		{
+			name:   "unsupported scheme",
+			member: map[string]interface{}{"uri": "ftp://consul/web"},
+			err:    "not supported uri scheme: ftp",
+		},
+	}
+
+	d := NewConsulDiscoverer()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			members, err := d.Discover(context.TODO(), "/T/A/web", c.member)
+			if c.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+					t.Errorf("got error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, m := range members {
+				got = append(got, m.Address)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEventDiscovererPool(t *testing.T) {
+	d := NewEventDiscoverer()
+	d.SetMembers("/T/A/web", []DiscoveredMember{{Address: "10.1.0.1"}, {Address: "10.1.0.2", Port: 8080}})
+	p, err := NewParser(WithMemberDiscoverer("event", d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restobjs, err := p.ParseAS3(context.TODO(), adc(`"A": {"class": "Application",
+		"web": {"class": "Pool", "members": [{"servicePort": 80, "addressDiscovery": "event"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := restobjs["T"].(map[string]interface{})["A"].(map[string]interface{})["ltm/pool/web"].(map[string]interface{})
+	got := []string{}
+	for _, m := range pool["members"].([]interface{}) {
+		got = append(got, m.(map[string]interface{})["name"].(string))
+	}
+	if want := []string{"10.1.0.1:80", "10.1.0.2:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+}
+
+func TestConsulDiscovererPool(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, consulCatalog)
+	}))
+	defer srv.Close()
+	ca, _ := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restobjs, err := p.ParseAS3(context.TODO(), adc(`"A": {"class": "Application",
+		"ca": {"class": "CA_Bundle", "bundle": `+string(ca)+`},
+		"web": {"class": "Pool", "members": [{"servicePort": 80, "addressDiscovery": "consul",
+			"uri": "`+srv.URL+`/v1/catalog/service/web", "trustCA": {"use": "ca"}}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := restobjs["T"].(map[string]interface{})["A"].(map[string]interface{})["ltm/pool/web"].(map[string]interface{})
+	got := []string{}
+	for _, m := range pool["members"].([]interface{}) {
+		got = append(got, m.(map[string]interface{})["name"].(string))
+	}
+	if want := []string{"10.0.0.1:80", "10.1.0.2:80"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+}
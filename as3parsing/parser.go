@@ -9,25 +9,37 @@ import (
 	"github.com/f5devcentral/f5-bigip-rest-go/utils"
 )
 
+// parse parses all the class objects in src,
+// the problems of all the objects are returned together as Diagnostics.
 func (pc *ParseContext) parse(src map[string]interface{}, objs map[string]interface{}) error {
-	var err error = nil
+	ds := Diagnostics{}
 	for k, v := range src {
+		if v == nil {
+			ds.add(k, newDiagError(CodeMalformed, "null value found for %s", k))
+			continue
+		}
 		t := reflect.TypeOf(v).Kind().String()
 		switch t {
 		case "map":
-			err = pc.parsemap(k, v, objs)
+			err := func() (err error) {
+				defer malformed(&err)
+				return pc.parsemap(k, v, objs)
+			}()
+			// AS3 and ADC are not part of the pointers into the declaration.
+			if cls := v.(map[string]interface{})["class"]; cls == "AS3" || cls == "ADC" {
+				ds.add("", err)
+			} else {
+				ds.add(k, err)
+			}
 		case "slice":
 		case "string":
 		case "bool":
 		case "float64":
 		default:
-			err = fmt.Errorf("unknown type found: %s for %s", t, k)
-		}
-		if err != nil {
-			break
+			ds.add(k, newDiagError(CodeMalformed, "unknown type found: %s for %s", t, k))
 		}
 	}
-	return err
+	return ds.err()
 }
 
 func (pc *ParseContext) parsemap(k string, v interface{}, objs map[string]interface{}) error {
@@ -67,6 +79,11 @@ func (pc *ParseContext) parsemap(k string, v interface{}, objs map[string]interf
 		case "SNAT_Pool":
 			restname := "ltm/snatpool/" + k
 			objs[restname] = v
+		case "Data_Group":
+			err = pc.parseDataGroup(k, v.(map[string]interface{}), objs)
+		case "Endpoint_Policy":
+			restname := "ltm/policy/" + k
+			objs[restname] = v
 		case "Service_Address":
 			restname := "ltm/virtual-address/" + k
 			objs[restname] = v
@@ -88,7 +105,7 @@ func (pc *ParseContext) parsemap(k string, v interface{}, objs map[string]interf
 			} else if matched, e := regexp.MatchString(prfPtn, cls.(string)); e == nil && matched {
 				err = pc.parseProfile(k, cls.(string), v, objs)
 			} else {
-				err = fmt.Errorf("unknown class: %s for %s", cls.(string), k)
+				err = newDiagError(CodeUnknownClass, "unknown class: %s for %s", cls.(string), k)
 			}
 		}
 	}
@@ -97,7 +114,7 @@ func (pc *ParseContext) parsemap(k string, v interface{}, objs map[string]interf
 
 func (pc *ParseContext) parseCertificate(name string, obj, objdst map[string]interface{}) error {
 	slog := utils.LogFromContext(pc)
-	cc := newConvertContext(pc.Context)
+	cc := newConvertContext(pc.Context, pc.Parser)
 	uploadsUrl := "shared/file-transfer/uploads"
 	fileDir := "file:/var/config/rest/downloads"
 	filenamePrefix := "__PARTITION____SUBFOLDER__"
@@ -184,6 +201,54 @@ func (pc *ParseContext) parseCABundle(name string, obj, objdst map[string]interf
 	return nil
 }
 
+func (pc *ParseContext) parseDataGroup(name string, obj, objdst map[string]interface{}) error {
+	uploadsUrl := "shared/file-transfer/uploads"
+	fileDir := "file:/var/config/rest/downloads"
+	filenamePrefix := "__PARTITION____SUBFOLDER__"
+
+	if st, f := obj["storageType"]; !f || st == "internal" {
+		objdst["ltm/data-group/internal/"+name] = obj
+		return nil
+	} else if st != "external" {
+		return fmt.Errorf("unknown data group storageType: %v", st)
+	}
+
+	objdst["ltm/data-group/external/"+name] = obj
+	keyType, _ := obj["keyDataType"].(string)
+	if path, f := obj["externalFilePath"]; f {
+		sp, ok := path.(string)
+		if !ok || !strings.HasPrefix(sp, "file:") {
+			return fmt.Errorf("externalFilePath of data group %s: only 'file:' is supported", name)
+		}
+		objdst["sys/file/data-group/"+name] = map[string]interface{}{
+			"name":       name,
+			"type":       keyType,
+			"sourcePath": sp,
+		}
+	} else if records, f := obj["records"]; f {
+		sep := ":="
+		if s, ok := obj["separator"].(string); ok {
+			sep = s
+		}
+		content, err := dataGroupFileContent(keyType, sep, records)
+		if err != nil {
+			return err
+		}
+		filename := filenamePrefix + "_" + "data_group-" + name
+		objdst["sys/file/data-group/"+name] = map[string]interface{}{
+			"name":       name,
+			"type":       keyType,
+			"sourcePath": fmt.Sprintf("%s/%s", fileDir, filename),
+		}
+		objdst[uploadsUrl+"/"+filename] = map[string]interface{}{
+			"content": content,
+		}
+	} else {
+		return fmt.Errorf("either externalFilePath or records is required for external data group %s", name)
+	}
+	return nil
+}
+
 func (pc *ParseContext) parsePersist(k string, v interface{}, objs map[string]interface{}) error {
 	var err error = nil
 	if t, f := v.(map[string]interface{})["persistenceMethod"]; f {
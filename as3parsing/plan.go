@@ -0,0 +1,219 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change is a field level difference of a REST resource body.
+type Change struct {
+	// Field is the json pointer into the body, i.e. /destination, named items are pointed by name, i.e. /members/10.0.0.1:80
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// Operation tells what to do with one REST resource, i.e. partition "T", folder "A", key "ltm/pool/web".
+type Operation struct {
+	Op        string `json:"op"`
+	Partition string `json:"partition"`
+	Folder    string `json:"folder"`
+	Key       string `json:"key"`
+	// Body is the new body for create and update, the old one for delete.
+	Body    map[string]interface{} `json:"body"`
+	Changes []Change               `json:"changes,omitempty"`
+}
+
+// Plan is the operations from one set of REST objects to another, sorted by partition, folder and key.
+type Plan []Operation
+
+// String renders the plan for preview and audit, one line per operation and per changed field.
+func (pl Plan) String() string {
+	lines := []string{}
+	for _, op := range pl {
+		lines = append(lines, fmt.Sprintf("%s /%s/%s %s", op.Op, op.Partition, op.Folder, op.Key))
+		for _, c := range op.Changes {
+			bold, _ := json.Marshal(c.Old)
+			bnew, _ := json.Marshal(c.New)
+			lines = append(lines, fmt.Sprintf("    %s: %s -> %s", c.Field, bold, bnew))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanAS3 parses both as3 bodies and diffs the REST objects, oldAS3 may be nil for the first deployment.
+func (p *Parser) PlanAS3(ctx context.Context, oldAS3, newAS3 map[string]interface{}) (Plan, error) {
+	oldobjs := map[string]interface{}{}
+	if oldAS3 != nil {
+		objs, err := p.ParseAS3(ctx, oldAS3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the old declaration: %w", err)
+		}
+		oldobjs = objs
+	}
+	newobjs, err := p.ParseAS3(ctx, newAS3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the new declaration: %w", err)
+	}
+	return DiffRest(oldobjs, newobjs), nil
+}
+
+// DiffRest compares two sets of REST objects in the format ParseAS3 returns:
+//
+//	partition -> folder -> "kind/name" -> body
+//
+// and returns the create, update and delete operations per resource.
+func DiffRest(oldobjs, newobjs map[string]interface{}) Plan {
+	plan := Plan{}
+	olds, news := flattenRest(oldobjs), flattenRest(newobjs)
+	for k, nbody := range news {
+		op := Operation{Partition: k[0], Folder: k[1], Key: k[2], Body: nbody}
+		if obody, f := olds[k]; !f {
+			op.Op = OpCreate
+		} else if changes := diffValue("", normalize(obody), normalize(nbody)); len(changes) > 0 {
+			op.Op, op.Changes = OpUpdate, changes
+		} else {
+			continue
+		}
+		plan = append(plan, op)
+	}
+	for k, obody := range olds {
+		if _, f := news[k]; !f {
+			plan = append(plan, Operation{Op: OpDelete, Partition: k[0], Folder: k[1], Key: k[2], Body: obody})
+		}
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		a, b := plan[i], plan[j]
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		if a.Folder != b.Folder {
+			return a.Folder < b.Folder
+		}
+		return a.Key < b.Key
+	})
+	return plan
+}
+
+// flattenRest indexes the resource bodies by [partition, folder, "kind/name"].
+func flattenRest(restobjs map[string]interface{}) map[[3]string]map[string]interface{} {
+	flat := map[[3]string]map[string]interface{}{}
+	for pn, pv := range restobjs {
+		partition, ok := pv.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for fn, fv := range partition {
+			folder, ok := fv.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for kn, kv := range folder {
+				if body, ok := kv.(map[string]interface{}); ok {
+					flat[[3]string{pn, fn, kn}] = body
+				}
+			}
+		}
+	}
+	return flat
+}
+
+// diffValue returns the changes from o to n, maps and slices are compared per field and per item.
+func diffValue(field string, o, n interface{}) []Change {
+	changes := []Change{}
+	if o == nil || n == nil || reflect.TypeOf(o).Kind() != reflect.TypeOf(n).Kind() {
+		if !equalValue(o, n) {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+		return changes
+	}
+	switch reflect.TypeOf(o).Kind().String() {
+	case "map":
+		omap, nmap := o.(map[string]interface{}), n.(map[string]interface{})
+		keys := []string{}
+		for k := range omap {
+			keys = append(keys, k)
+		}
+		for k := range nmap {
+			if _, f := omap[k]; !f {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ptr := strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+			changes = append(changes, diffValue(field+"/"+ptr, omap[k], nmap[k])...)
+		}
+	case "slice":
+		oslice, nslice := o.([]interface{}), n.([]interface{})
+		// items with names, i.e. pool members, are compared by name rather than position.
+		onamed, nnamed := namedItems(oslice), namedItems(nslice)
+		if onamed != nil && nnamed != nil {
+			return diffValue(field, onamed, nnamed)
+		}
+		for i := 0; i < len(oslice) || i < len(nslice); i++ {
+			var ov, nv interface{}
+			if i < len(oslice) {
+				ov = oslice[i]
+			}
+			if i < len(nslice) {
+				nv = nslice[i]
+			}
+			changes = append(changes, diffValue(fmt.Sprintf("%s/%d", field, i), ov, nv)...)
+		}
+	default:
+		if !equalValue(o, n) {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// namedItems indexes the items by their names, it returns nil if any item has no name.
+func namedItems(items []interface{}) map[string]interface{} {
+	named := map[string]interface{}{}
+	for _, item := range items {
+		mitem, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		name, ok := mitem["name"].(string)
+		if !ok {
+			return nil
+		}
+		if _, f := named[name]; f {
+			return nil
+		}
+		named[name] = item
+	}
+	return named
+}
+
+// normalize turns the body to its json form, i.e. []string to []interface{}, for comparing.
+func normalize(body map[string]interface{}) interface{} {
+	var v interface{}
+	if b, err := json.Marshal(body); err == nil && json.Unmarshal(b, &v) == nil {
+		return v
+	}
+	return body
+}
+
+// equalValue compares the values in their json form, so that 80 and 80.0 from different sources are equal.
+func equalValue(o, n interface{}) bool {
+	bo, err1 := json.Marshal(o)
+	bn, err2 := json.Marshal(n)
+	if err1 != nil || err2 != nil {
+		return reflect.DeepEqual(o, n)
+	}
+	return string(bo) == string(bn)
+}
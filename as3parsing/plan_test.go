@@ -0,0 +1,94 @@
+package as3parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffRest(t *testing.T) {
+	pool := func(members ...string) map[string]interface{} {
+		items := []interface{}{}
+		for _, m := range members {
+			items = append(items, map[string]interface{}{"name": m, "ratio": 1})
+		}
+		return map[string]interface{}{"name": "web", "monitor": "/Common/http", "members": items}
+	}
+	rest := func(objs map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"T": map[string]interface{}{"A": objs}}
+	}
+
+	cases := []struct {
+		name string
+		old  map[string]interface{}
+		new  map[string]interface{}
+		want []string
+	}{
+		{
+			name: "nothing changed",
+			old:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.1:80")}),
+			new:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.1:80")}),
+			want: []string{},
+		},
+		{
+			name: "first deployment",
+			old:  map[string]interface{}{},
+			new:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.1:80")}),
+			want: []string{"create /T/A ltm/pool/web"},
+		},
+		{
+			name: "resource removed",
+			old: rest(map[string]interface{}{
+				"ltm/pool/web":       pool("10.0.0.1:80"),
+				"ltm/monitor/http/m": map[string]interface{}{"name": "m"},
+			}),
+			new:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.1:80")}),
+			want: []string{"delete /T/A ltm/monitor/http/m"},
+		},
+		{
+			name: "field changed",
+			old:  rest(map[string]interface{}{"ltm/virtual/vs": map[string]interface{}{"name": "vs", "pool": "/T/A/web"}}),
+			new:  rest(map[string]interface{}{"ltm/virtual/vs": map[string]interface{}{"name": "vs", "pool": "/T/A/web2"}}),
+			want: []string{"update /T/A ltm/virtual/vs", `    /pool: "/T/A/web" -> "/T/A/web2"`},
+		},
+		{
+			name: "members compared by name",
+			old:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.1:80", "10.0.0.2:80")}),
+			new:  rest(map[string]interface{}{"ltm/pool/web": pool("10.0.0.2:80", "10.0.0.3:80")}),
+			want: []string{
+				"update /T/A ltm/pool/web",
+				`    /members/10.0.0.1:80: {"name":"10.0.0.1:80","ratio":1} -> null`,
+				`    /members/10.0.0.3:80: null -> {"name":"10.0.0.3:80","ratio":1}`,
+			},
+		},
+		{
+			name: "numbers of different sources",
+			old:  rest(map[string]interface{}{"ltm/monitor/http/m": map[string]interface{}{"name": "m", "interval": 5}}),
+			new:  rest(map[string]interface{}{"ltm/monitor/http/m": map[string]interface{}{"name": "m", "interval": 5.0}}),
+			want: []string{},
+		},
+		{
+			name: "sorted by partition, folder and key",
+			old:  map[string]interface{}{},
+			new: map[string]interface{}{
+				"T2": map[string]interface{}{"": map[string]interface{}{"ltm/virtual-address/10.0.0.2": map[string]interface{}{}}},
+				"T1": map[string]interface{}{
+					"A": map[string]interface{}{"ltm/virtual/vs": map[string]interface{}{}, "ltm/pool/web": map[string]interface{}{}},
+				},
+			},
+			want: []string{
+				"create /T1/A ltm/pool/web",
+				"create /T1/A ltm/virtual/vs",
+				"create /T2/ ltm/virtual-address/10.0.0.2",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := DiffRest(c.old, c.new).String()
+			if want := strings.Join(c.want, "\n"); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
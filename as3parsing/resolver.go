@@ -0,0 +1,241 @@
+package as3parsing
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/f5devcentral/f5-bigip-rest-go/utils"
+)
+
+// refResolver resolves the 'use' pointers of a declaration to full BIG-IP paths, i.e. /Tenant/App/item.
+type refResolver struct {
+	// items holds the full paths of all the declared items, in format of: /tenant/app/item -> class
+	items map[string]string
+	// tenants holds the names of the declared tenants
+	tenants map[string]bool
+	ds      Diagnostics
+}
+
+// pointerProperties are the properties of Service_* whose strings are pointers as the 'use' ones, i.e. "pool": "web".
+var pointerProperties = []string{"pool", "serverTLS", "clientTLS", "policyEndpoint", "iRules"}
+
+// resolveReferences rewrites all the 'use' pointers, and the string pointers of pointerProperties and Pool monitors,
+// in the declaration to full paths:
+//
+//	"pool"              -> the item in the same application, then in /<tenant>/Shared and /Common/Shared
+//	"App/pool"          -> /<tenant>/App/pool
+//	"/@/App/pool"       -> /<tenant>/App/pool, '@' stands for the referring tenant or application
+//	"/Tenant/App/pool"  -> as it is
+//
+// The pointers that cannot be found in the declaration are reported as Diagnostics with the referring json path,
+// except the absolute paths out of the declared tenants, i.e. /Common/_sys_https_redirect, and the monitor
+// strings, which are kept as the BIG-IP built-in monitors, i.e. "http".
+func resolveReferences(decl map[string]interface{}) error {
+	r := newRefResolver(decl)
+	for tn, tv := range decl {
+		tenant, ok := tv.(map[string]interface{})
+		if !ok || tenant["class"] != "Tenant" {
+			continue
+		}
+		for an, av := range tenant {
+			app, ok := av.(map[string]interface{})
+			if !ok || app["class"] != "Application" {
+				continue
+			}
+			for k, v := range app {
+				jpath := strings.Join([]string{tn, an, k}, "/")
+				if item, ok := v.(map[string]interface{}); ok {
+					r.resolveStrings(item, tn, an, jpath)
+				}
+				r.walk(v, tn, an, jpath)
+			}
+		}
+	}
+	return r.ds.err()
+}
+
+func newRefResolver(decl map[string]interface{}) *refResolver {
+	r := &refResolver{items: map[string]string{}, tenants: map[string]bool{}, ds: Diagnostics{}}
+	for tn, tv := range decl {
+		tenant, ok := tv.(map[string]interface{})
+		if !ok || tenant["class"] != "Tenant" {
+			continue
+		}
+		r.tenants[tn] = true
+		for an, av := range tenant {
+			app, ok := av.(map[string]interface{})
+			if !ok || app["class"] != "Application" {
+				continue
+			}
+			for k, v := range app {
+				if item, ok := v.(map[string]interface{}); ok {
+					if cls, ok := item["class"].(string); ok {
+						r.items["/"+strings.Join([]string{tn, an, k}, "/")] = cls
+					}
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (r *refResolver) walk(obj interface{}, tenant, app, jpath string) {
+	if obj == nil {
+		return
+	}
+	switch reflect.TypeOf(obj).Kind().String() {
+	case "map":
+		mobj := obj.(map[string]interface{})
+		if use, f := mobj["use"]; f {
+			ref, ok := use.(string)
+			if !ok {
+				r.ds.add(jpath+"/use", newDiagError(CodeInvalidValue, "invalid reference %v", use))
+				return
+			}
+			if fp, err := r.resolve(ref, tenant, app); err != nil {
+				r.ds.add(jpath+"/use", err)
+			} else {
+				mobj["use"] = fp
+			}
+			return
+		}
+		for k, v := range mobj {
+			r.walk(v, tenant, app, jpath+"/"+k)
+		}
+	case "slice":
+		for i, v := range obj.([]interface{}) {
+			r.walk(v, tenant, app, fmt.Sprintf("%s/%d", jpath, i))
+		}
+	}
+}
+
+// resolveStrings resolves the string pointers of the item.
+func (r *refResolver) resolveStrings(item map[string]interface{}, tenant, app, jpath string) {
+	cls, _ := item["class"].(string)
+	if strings.HasPrefix(cls, "Service_") {
+		for _, k := range pointerProperties {
+			r.resolveString(item, k, tenant, app, jpath+"/"+k, true)
+		}
+	} else if cls == "Pool" {
+		r.resolveString(item, "monitors", tenant, app, jpath+"/monitors", false)
+	}
+}
+
+// resolveString resolves the string, or the strings in the array, of the property k of obj.
+// The ones not found are reported as dangling if strict, or kept as they are.
+func (r *refResolver) resolveString(obj map[string]interface{}, k, tenant, app, jpath string, strict bool) {
+	resolve := func(ref, jp string) string {
+		if ref == "" {
+			return ref
+		}
+		fp, err := r.resolve(ref, tenant, app)
+		if err != nil {
+			if strict && !r.external(ref) {
+				r.ds.add(jp, err)
+			}
+			return ref
+		}
+		return fp
+	}
+	if ref, ok := obj[k].(string); ok {
+		obj[k] = resolve(ref, jpath)
+	} else if items, ok := obj[k].([]interface{}); ok {
+		for i, item := range items {
+			if ref, ok := item.(string); ok {
+				items[i] = resolve(ref, fmt.Sprintf("%s/%d", jpath, i))
+			}
+		}
+	}
+}
+
+// external tells if ref is an absolute path out of the declared tenants, i.e. /Common/_sys_https_redirect.
+func (r *refResolver) external(ref string) bool {
+	if !strings.HasPrefix(ref, "/") {
+		return false
+	}
+	segs := strings.Split(ref[1:], "/")
+	if segs[0] == "Common" {
+		return len(segs) < 2 || segs[1] != "Shared"
+	}
+	return !r.tenants[segs[0]] && segs[0] != "@"
+}
+
+// resolve returns the full path of ref referred from /tenant/app.
+func (r *refResolver) resolve(ref, tenant, app string) (string, error) {
+	if ref == "" {
+		return "", newDiagError(CodeInvalidValue, "empty reference")
+	}
+	candidates := []string{}
+	if strings.HasPrefix(ref, "/") {
+		segs := strings.Split(ref[1:], "/")
+		if len(segs) > 0 && segs[0] == "@" {
+			segs[0] = tenant
+			if len(segs) > 1 && segs[1] == "@" {
+				segs[1] = app
+			}
+		}
+		candidates = append(candidates, "/"+strings.Join(segs, "/"))
+	} else {
+		segs := strings.Split(ref, "/")
+		switch len(segs) {
+		case 1:
+			candidates = append(candidates,
+				"/"+utils.Keyname(tenant, app, ref),
+				"/"+utils.Keyname(tenant, "Shared", ref),
+				"/"+utils.Keyname("Common", "Shared", ref),
+			)
+		case 2:
+			candidates = append(candidates, "/"+utils.Keyname(tenant, ref))
+		default:
+			return "", newDiagError(CodeInvalidValue, "invalid reference '%s'", ref)
+		}
+	}
+	for _, c := range candidates {
+		if _, f := r.items[c]; f {
+			return c, nil
+		}
+		// pointer to a property of an item, i.e. /tenant/app/cert/certificate
+		segs := strings.Split(c[1:], "/")
+		if len(segs) > 3 {
+			if _, f := r.items["/"+strings.Join(segs[:3], "/")]; f {
+				return c, nil
+			}
+		}
+	}
+	return "", newDiagError(CodeDanglingReference, "dangling reference '%s'", ref)
+}
+
+// splitPath splits the full path /tenant/app/item to "/tenant/app" and "item".
+// For a relative name, parent is returned as the folder.
+func splitPath(parent, ref string) (string, string) {
+	if !strings.HasPrefix(ref, "/") {
+		return parent, ref
+	}
+	i := strings.LastIndex(ref, "/")
+	return ref[:i], ref[i+1:]
+}
+
+// lookup finds the parsed object "kind/name" referred by ref, which is
+// either a name in objsrc or a full path in the whole parsed tree.
+func (cc *ConvertContext) lookup(kind, parent, ref string, objsrc map[string]interface{}) (interface{}, bool) {
+	folder, name := splitPath(parent, ref)
+	if folder == parent {
+		obj, f := objsrc[kind+"/"+name]
+		return obj, f
+	}
+	segs := strings.Split(strings.TrimPrefix(folder, "/"), "/")
+	if len(segs) != 2 || cc.parsed == nil {
+		return nil, false
+	}
+	tenant, ok := cc.parsed[segs[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	app, ok := tenant[segs[1]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	obj, f := app[kind+"/"+name]
+	return obj, f
+}
@@ -0,0 +1,144 @@
+package as3parsing
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	cases := []struct {
+		name string
+		// item is the item "it" added to /T/A, prop is its property checked against want.
+		item, prop, want string
+		diagnostics      []string
+	}{
+		{
+			name: "relative",
+			item: `{"class": "Service_HTTP", "pool": "web"}`,
+			prop: "pool", want: `"/T/A/web"`,
+		},
+		{
+			name: "use",
+			item: `{"class": "Service_HTTP", "pool": {"use": "web"}}`,
+			prop: "pool", want: `{"use":"/T/A/web"}`,
+		},
+		{
+			name: "App/item",
+			item: `{"class": "Service_HTTP", "pool": "B/bp"}`,
+			prop: "pool", want: `"/T/B/bp"`,
+		},
+		{
+			name: "/@/App/item",
+			item: `{"class": "Service_HTTP", "pool": {"use": "/@/B/bp"}}`,
+			prop: "pool", want: `{"use":"/T/B/bp"}`,
+		},
+		{
+			name: "/@/@/item",
+			item: `{"class": "Service_HTTP", "pool": "/@/@/web"}`,
+			prop: "pool", want: `"/T/A/web"`,
+		},
+		{
+			name: "tenant Shared fallback",
+			item: `{"class": "Service_HTTP", "pool": "sp"}`,
+			prop: "pool", want: `"/T/Shared/sp"`,
+		},
+		{
+			name: "Common Shared fallback",
+			item: `{"class": "Service_HTTP", "pool": "cp"}`,
+			prop: "pool", want: `"/Common/Shared/cp"`,
+		},
+		{
+			name: "other tenant",
+			item: `{"class": "Service_HTTP", "pool": "/T2/X/xp"}`,
+			prop: "pool", want: `"/T2/X/xp"`,
+		},
+		{
+			name: "property of an item",
+			item: `{"class": "TLS_Server", "certificates": [{"certificate": {"use": "/T/A/web/members"}}]}`,
+			prop: "certificates", want: `[{"certificate":{"use":"/T/A/web/members"}}]`,
+		},
+		{
+			name: "external Common paths",
+			item: `{"class": "Service_HTTP", "iRules": ["/Common/_sys_https_redirect"], "profileHTTP": {"bigip": "/Common/http"}}`,
+			prop: "iRules", want: `["/Common/_sys_https_redirect"]`,
+		},
+		{
+			name: "built-in monitors",
+			item: `{"class": "Pool", "monitors": ["http", "m", "nope"]}`,
+			prop: "monitors", want: `["http","/T/A/m","nope"]`,
+		},
+		{
+			name:        "dangling string",
+			item:        `{"class": "Service_HTTP", "pool": "nope"}`,
+			prop:        "pool",
+			want:        `"nope"`,
+			diagnostics: []string{"/T/A/it/pool dangling-reference"},
+		},
+		{
+			name:        "dangling use",
+			item:        `{"class": "Service_HTTP", "pool": {"use": "nope"}}`,
+			prop:        "pool",
+			want:        `{"use":"nope"}`,
+			diagnostics: []string{"/T/A/it/pool/use dangling-reference"},
+		},
+		{
+			name:        "dangling item in array",
+			item:        `{"class": "Service_HTTP", "iRules": ["r", "nope"]}`,
+			prop:        "iRules",
+			want:        `["/T/A/r","nope"]`,
+			diagnostics: []string{"/T/A/it/iRules/1 dangling-reference"},
+		},
+		{
+			name:        "dangling in declared Common Shared",
+			item:        `{"class": "Service_HTTP", "pool": "/Common/Shared/nope"}`,
+			prop:        "pool",
+			want:        `"/Common/Shared/nope"`,
+			diagnostics: []string{"/T/A/it/pool dangling-reference"},
+		},
+		{
+			name:        "dangling in declared tenant",
+			item:        `{"class": "Service_HTTP", "policyEndpoint": "/T2/X/nope"}`,
+			prop:        "policyEndpoint",
+			want:        `"/T2/X/nope"`,
+			diagnostics: []string{"/T/A/it/policyEndpoint dangling-reference"},
+		},
+		{
+			name:        "invalid reference",
+			item:        `{"class": "Service_HTTP", "pool": "a/b/c"}`,
+			prop:        "pool",
+			want:        `"a/b/c"`,
+			diagnostics: []string{"/T/A/it/pool invalid-value"},
+		},
+		{
+			name:        "non-string use",
+			item:        `{"class": "Pool", "members": [{"servicePort": 80, "monitors": [{"use": 1}]}]}`,
+			prop:        "members",
+			want:        `[{"monitors":[{"use":1}],"servicePort":80}]`,
+			diagnostics: []string{"/T/A/it/members/0/monitors/0/use invalid-value"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			decl := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(`{"class": "ADC",
+				"T": {"class": "Tenant",
+					"A": {"class": "Application", "it": `+c.item+`,
+						"web": {"class": "Pool"}, "m": {"class": "Monitor"}, "r": {"class": "iRule"}},
+					"B": {"class": "Application", "bp": {"class": "Pool"}},
+					"Shared": {"class": "Application", "sp": {"class": "Pool"}}},
+				"T2": {"class": "Tenant", "X": {"class": "Application", "xp": {"class": "Pool"}}},
+				"Common": {"class": "Tenant", "Shared": {"class": "Application", "cp": {"class": "Pool"}}}}`), &decl); err != nil {
+				t.Fatal(err)
+			}
+			err := resolveReferences(decl)
+			if got, want := diagnostics(err), strings.Join(c.diagnostics, "\n"); got != want {
+				t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, want)
+			}
+			item := decl["T"].(map[string]interface{})["A"].(map[string]interface{})["it"].(map[string]interface{})
+			if got, _ := json.Marshal(item[c.prop]); string(got) != c.want {
+				t.Errorf("got %s %s, want %s", c.prop, got, c.want)
+			}
+		})
+	}
+}
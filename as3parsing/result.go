@@ -0,0 +1,204 @@
+package as3parsing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Result is the typed form of the ParseAS3 output:
+//
+//	partition -> folder -> "kind/name" -> body
+//
+// Partitions, folders and resources are sorted by name.
+type Result struct {
+	Partitions []*Partition `json:"partitions"`
+}
+
+// Partition is a BIG-IP partition, i.e. the as3 tenant.
+type Partition struct {
+	Name    string    `json:"name"`
+	Folders []*Folder `json:"folders"`
+}
+
+// Folder is a folder in the partition, i.e. the as3 application, "" for the partition itself.
+type Folder struct {
+	Name      string      `json:"name"`
+	Partition string      `json:"partition"`
+	Resources []*Resource `json:"resources"`
+}
+
+// Resource is one REST resource, i.e. kind "ltm/monitor/http" and name "mon".
+type Resource struct {
+	Partition string                 `json:"partition"`
+	Folder    string                 `json:"folder"`
+	Kind      string                 `json:"kind"`
+	Name      string                 `json:"name"`
+	Body      map[string]interface{} `json:"body"`
+}
+
+// ParseAS3Result is ParseAS3 returning the typed Result.
+func (p *Parser) ParseAS3Result(ctx context.Context, as3obj map[string]interface{}) (*Result, error) {
+	restobjs, err := p.ParseAS3(ctx, as3obj)
+	if err != nil {
+		return nil, err
+	}
+	return NewResult(restobjs)
+}
+
+// NewResult converts the REST objects in the format ParseAS3 returns to Result.
+// The bodies are shared, not copied.
+func NewResult(restobjs map[string]interface{}) (*Result, error) {
+	r := &Result{Partitions: []*Partition{}}
+	for _, pn := range sortedKeys(restobjs) {
+		pobj, ok := restobjs[pn].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("partition %s is not an object", pn)
+		}
+		partition := &Partition{Name: pn, Folders: []*Folder{}}
+		for _, fn := range sortedKeys(pobj) {
+			fobj, ok := pobj[fn].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("folder /%s/%s is not an object", pn, fn)
+			}
+			folder := &Folder{Name: fn, Partition: pn, Resources: []*Resource{}}
+			for _, key := range sortedKeys(fobj) {
+				body, ok := fobj[key].(map[string]interface{})
+				i := strings.LastIndex(key, "/")
+				if !ok || i <= 0 {
+					return nil, fmt.Errorf("resource %s in /%s/%s is not valid", key, pn, fn)
+				}
+				folder.Resources = append(folder.Resources, &Resource{
+					Partition: pn,
+					Folder:    fn,
+					Kind:      key[:i],
+					Name:      key[i+1:],
+					Body:      body,
+				})
+			}
+			partition.Folders = append(partition.Folders, folder)
+		}
+		r.Partitions = append(r.Partitions, partition)
+	}
+	return r, nil
+}
+
+// Map converts the Result back to the format ParseAS3 returns.
+func (r *Result) Map() map[string]interface{} {
+	restobjs := map[string]interface{}{}
+	for _, p := range r.Partitions {
+		pobj := map[string]interface{}{}
+		for _, f := range p.Folders {
+			fobj := map[string]interface{}{}
+			for _, res := range f.Resources {
+				fobj[res.Key()] = res.Body
+			}
+			pobj[f.Name] = fobj
+		}
+		restobjs[p.Name] = pobj
+	}
+	return restobjs
+}
+
+// Partition returns the partition of the name.
+func (r *Result) Partition(name string) (*Partition, bool) {
+	for _, p := range r.Partitions {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Folder returns the folder of the name, "" for the partition itself.
+func (p *Partition) Folder(name string) (*Folder, bool) {
+	for _, f := range p.Folders {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Each calls fn with every resource in order, it stops at the first error.
+func (r *Result) Each(fn func(res *Resource) error) error {
+	for _, p := range r.Partitions {
+		for _, f := range p.Folders {
+			for _, res := range f.Resources {
+				if err := fn(res); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Resources returns the resources of the kind, i.e. "ltm/pool", or all of them for kind "".
+func (r *Result) Resources(kind string) []*Resource {
+	rs := []*Resource{}
+	r.Each(func(res *Resource) error {
+		if kind == "" || res.Kind == kind {
+			rs = append(rs, res)
+		}
+		return nil
+	})
+	return rs
+}
+
+// Lookup finds the resource of the kind by its full path, i.e. "ltm/pool", "/T/A/web",
+// or "/T/10.0.0.1" for the ones in the partition itself.
+func (r *Result) Lookup(kind, fullpath string) (*Resource, bool) {
+	segs := strings.Split(strings.TrimPrefix(fullpath, "/"), "/")
+	var pn, fn, name string
+	switch len(segs) {
+	case 2:
+		pn, name = segs[0], segs[1]
+	case 3:
+		pn, fn, name = segs[0], segs[1], segs[2]
+	default:
+		return nil, false
+	}
+	p, found := r.Partition(pn)
+	if !found {
+		return nil, false
+	}
+	f, found := p.Folder(fn)
+	if !found {
+		return nil, false
+	}
+	for _, res := range f.Resources {
+		if res.Kind == kind && res.Name == name {
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+// Key is the "kind/name" key of the resource in the folder.
+func (res *Resource) Key() string {
+	return res.Kind + "/" + res.Name
+}
+
+// FullPath is the BIG-IP full path of the resource, i.e. /T/A/web.
+func (res *Resource) FullPath() string {
+	if res.Folder == "" {
+		return fmt.Sprintf("/%s/%s", res.Partition, res.Name)
+	}
+	return fmt.Sprintf("/%s/%s/%s", res.Partition, res.Folder, res.Name)
+}
+
+// ID identifies the resource in the DependencyGraph.
+func (res *Resource) ID() ResourceID {
+	return ResourceID{Partition: res.Partition, Folder: res.Folder, Key: res.Key()}
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := []string{}
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
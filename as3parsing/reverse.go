@@ -0,0 +1,1372 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/f5devcentral/f5-bigip-rest-go/utils"
+)
+
+// RestToAS3 rebuilds the as3 body from the REST objects in format of:
+//
+//	partition -> folder -> "kind/name" -> body
+//
+// i.e. the result of ParseAS3, or the objects read from iControl REST.
+// The "" folder of each partition, holding the nodes and virtual addresses created along with
+// pools and services, is not converted. The problems are returned as Diagnostics,
+// pointing to /partition/folder/name of restobjs.
+func (p *Parser) RestToAS3(ctx context.Context, restobjs map[string]interface{}) (map[string]interface{}, error) {
+	defer utils.TimeItToPrometheus()()
+	rc := newReverseContext(ctx, p, restobjs)
+	declaration := map[string]interface{}{
+		"class":         "ADC",
+		"schemaVersion": "3.0.0",
+	}
+	ds := Diagnostics{}
+	for pn, pv := range restobjs {
+		partition, ok := pv.(map[string]interface{})
+		if !ok {
+			ds.add(pn, newDiagError(CodeMalformed, "partition %s should be an object", pn))
+			continue
+		}
+		tenant := map[string]interface{}{
+			"class": "Tenant",
+		}
+		for fn, fv := range partition {
+			if fn == "" {
+				continue
+			}
+			folder, ok := fv.(map[string]interface{})
+			if !ok {
+				ds.add(pn+"/"+fn, newDiagError(CodeMalformed, "folder %s should be an object", fn))
+				continue
+			}
+			app := map[string]interface{}{
+				"class":    "Application",
+				"template": "generic",
+			}
+			if fn == "Shared" {
+				app["template"] = "shared"
+			}
+			ds.add(pn+"/"+fn, rc.reverse("/"+pn+"/"+fn, folder, app))
+			tenant[fn] = app
+		}
+		declaration[pn] = tenant
+	}
+
+	return map[string]interface{}{
+		"class":       "AS3",
+		"action":      "deploy",
+		"persist":     true,
+		"declaration": declaration,
+	}, ds.err()
+}
+
+// indexedPattern matches the names made by indexedName for the items other than the first, i.e. "tls-1-",
+// the client-ssl profiles of the SNI certificates, or "vs-1-", the virtuals of the other virtualAddresses.
+var indexedPattern = regexp.MustCompile(`^(.+)-\d+-$`)
+
+// reverse converts all the REST objects in folder to the as3 classes of app.
+func (rc *ReverseContext) reverse(parent string, folder, app map[string]interface{}) error {
+	slog := utils.LogFromContext(rc)
+	keys := []string{}
+	for k := range folder {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ds := Diagnostics{}
+	for _, k := range keys {
+		tn := strings.Split(k, "/")
+		if len(tn) < 3 {
+			continue
+		}
+		name := tn[len(tn)-1]
+		err := func() (err error) {
+			defer malformed(&err)
+			body, ok := folder[k].(map[string]interface{})
+			if !ok {
+				return newDiagError(CodeMalformed, "%s should be an object", k)
+			}
+			var obj map[string]interface{}
+			switch strings.Join(tn[0:2], "/") {
+			case "ltm/virtual":
+				vname := name
+				if m := indexedPattern.FindStringSubmatch(name); m != nil {
+					if _, f := folder["ltm/virtual/"+m[1]]; f {
+						// merged into the virtualAddresses of the first one.
+						return nil
+					}
+					vname = m[1]
+				}
+				if strings.HasSuffix(vname, "-Redirect-") {
+					// created for redirect80 of Service_HTTPS.
+					return nil
+				}
+				obj, err = rc.reverseVirtual(parent, name, body, folder)
+			case "ltm/pool":
+				obj, err = rc.reversePool(parent, body)
+			case "ltm/monitor":
+				obj, err = rc.reverseMonitor(parent, tn[2], body, app)
+			case "ltm/profile":
+				if m := indexedPattern.FindStringSubmatch(name); m != nil && tn[2] == "client-ssl" {
+					if _, f := folder["ltm/profile/client-ssl/"+m[1]]; f {
+						// merged into the certificates of the TLS_Server.
+						return nil
+					}
+				}
+				obj, err = rc.reverseProfile(parent, tn[2], name, body, folder, app)
+			case "ltm/persistence":
+				obj, err = rc.reversePersist(tn[2], body)
+			case "ltm/rule":
+				obj = map[string]interface{}{"class": "iRule"}
+				rc.reverseProperties("ltm/rule", body, obj)
+			case "ltm/snatpool":
+				if selfSnatPool(name, folder) {
+					// created for snat 'self' of the virtual.
+					return nil
+				}
+				obj = map[string]interface{}{"class": "SNAT_Pool"}
+				rc.reverseProperties("ltm/snatpool", body, obj)
+			case "ltm/virtual-address":
+				obj, err = rc.reverseVirtualAddress(body)
+			case "ltm/data-group":
+				obj, err = rc.reverseDataGroup(parent, tn[2], body)
+			case "ltm/policy":
+				obj, err = rc.reversePolicy(parent, body)
+			case "ltm/node", "shared/file-transfer", "sys/file":
+				// referred by pools and certificates.
+				return nil
+			default:
+				slog.Warnf("not supported to convert %s to as3 yet, skipped", k)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			app[name] = obj
+			return nil
+		}()
+		ds.add(name, err)
+	}
+	return ds.err()
+}
+
+func (rc *ReverseContext) reverseVirtual(parent, name string, body, folder map[string]interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	kinds := map[string]bool{}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/virtual", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name", "source", "mask":
+		case "destination":
+			addr, port, err := splitDestination(v.(string))
+			if err != nil {
+				return err
+			}
+			obj["virtualAddresses"] = []interface{}{reverseVirtualAddr(addr, body)}
+			obj["virtualPort"] = port
+			if strings.HasPrefix(v.(string), "/Common/") && !strings.HasPrefix(parent, "/Common/") {
+				obj["shareAddresses"] = true
+			}
+		case "description":
+			// set to the application name by default.
+			if v != strings.TrimPrefix(parent[strings.LastIndex(parent, "/"):], "/") {
+				obj["remark"] = v
+			}
+		case "pool":
+			if v != "" {
+				obj["pool"] = rc.reverseRef(parent, v.(string))
+			}
+		case "profiles":
+			for _, item := range v.([]interface{}) {
+				pn := item.(map[string]interface{})["name"].(string)
+				kind, f := rc.profileKind(parent, pn)
+				if !f {
+					return fmt.Errorf("cannot decide the kind of profile %s", pn)
+				}
+				if m := indexedPattern.FindStringSubmatch(pn); m != nil && kind == "client-ssl" {
+					continue
+				}
+				kinds[kind] = true
+				as3name := profileProperties[kind]
+				if alias, f := wellKnownProfiles[pn]; f {
+					obj[as3name] = alias
+				} else {
+					obj[as3name] = rc.reverseRef(parent, pn)
+				}
+			}
+		case "persist":
+			methods := []interface{}{}
+			for _, item := range v.([]interface{}) {
+				pn := item.(map[string]interface{})["name"].(string)
+				if _, f := rc.lookup("ltm/persistence", parent, pn); f {
+					methods = append(methods, rc.reverseRef(parent, pn))
+				} else {
+					methods = append(methods, unrenamePersist(pn))
+				}
+			}
+			obj["persistenceMethods"] = methods
+		case "rules":
+			rules := []interface{}{}
+			for _, item := range v.([]interface{}) {
+				rules = append(rules, rc.reverseRef(parent, item.(string)))
+			}
+			obj["iRules"] = rules
+		case "policies":
+			policies := []interface{}{}
+			for _, item := range v.([]interface{}) {
+				policies = append(policies, rc.reverseRef(parent, item.(map[string]interface{})["name"].(string)))
+			}
+			obj["policyEndpoint"] = policies
+		case "sourceAddressTranslation":
+			snat := v.(map[string]interface{})
+			switch snat["type"] {
+			case "automap":
+				obj["snat"] = "auto"
+			case "snat":
+				pool, ok := snat["pool"].(string)
+				if !ok {
+					return newDiagError(CodeInvalidValue, "pool of sourceAddressTranslation should be a string: %v", snat["pool"])
+				}
+				if pool == name+"-self" {
+					obj["snat"] = "self"
+				} else {
+					obj["snat"] = rc.reverseRef(parent, pool)
+				}
+			default:
+				obj["snat"] = "none"
+			}
+		case "ipForward", "l2Forward":
+			if v == true {
+				obj["forwardingType"] = map[string]string{"ipForward": "ip", "l2Forward": "L2"}[k]
+			}
+		case "mirror":
+			if v == "enabled" {
+				obj["mirroring"] = "L4"
+			} else {
+				obj["mirroring"] = "none"
+			}
+		default:
+			rc.reverseProperties("ltm/virtual", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	// the virtuals of the other virtualAddresses, see convertVirtual.
+	for i := 1; ; i++ {
+		vname := indexedName(i, name)
+		vbody, ok := folder["ltm/virtual/"+vname].(map[string]interface{})
+		if !ok {
+			break
+		}
+		dest, ok := vbody["destination"].(string)
+		if !ok {
+			return nil, newDiagError(CodeInvalidValue, "destination of %s should be a string: %v", vname, vbody["destination"])
+		}
+		addr, _, err := splitDestination(dest)
+		if err != nil {
+			return nil, err
+		}
+		addrs, _ := obj["virtualAddresses"].([]interface{})
+		obj["virtualAddresses"] = append(addrs, reverseVirtualAddr(addr, vbody))
+	}
+	if _, f := obj["persistenceMethods"]; !f {
+		// or as3 would add the default ones.
+		obj["persistenceMethods"] = []interface{}{}
+	}
+
+	switch {
+	case obj["forwardingType"] != nil:
+		obj["class"] = "Service_Forwarding"
+		// always disabled for forwarding virtuals, and no persistence by default.
+		delete(obj, "translateServerAddress")
+		delete(obj, "translateServerPort")
+		if methods, _ := obj["persistenceMethods"].([]interface{}); len(methods) == 0 {
+			delete(obj, "persistenceMethods")
+		}
+	case kinds["sctp"] || obj["layer4"] == "sctp":
+		obj["class"] = "Service_SCTP"
+	case kinds["client-ssl"]:
+		obj["class"] = "Service_HTTPS"
+		_, f := folder["ltm/virtual/"+name+"-Redirect-"]
+		obj["redirect80"] = f
+	case kinds["http"]:
+		obj["class"] = "Service_HTTP"
+	case kinds["fastl4"]:
+		obj["class"] = "Service_L4"
+	case obj["layer4"] == "udp":
+		obj["class"] = "Service_UDP"
+	case kinds["tcp"]:
+		obj["class"] = "Service_TCP"
+	default:
+		obj["class"] = "Service_Generic"
+	}
+	return obj, nil
+}
+
+// selfSnatPool tells if the snatpool name is the one created for snat 'self' of a virtual in folder, i.e. "vs-self" or "vs-1--self".
+func selfSnatPool(name string, folder map[string]interface{}) bool {
+	vname := strings.TrimSuffix(name, "-self")
+	if vname == name {
+		return false
+	}
+	vbody, _ := folder["ltm/virtual/"+vname].(map[string]interface{})
+	snat, _ := vbody["sourceAddressTranslation"].(map[string]interface{})
+	return snat["pool"] == name
+}
+
+// reverseVirtualAddr rebuilds the item of virtualAddresses from the destination address, the mask and the source:
+// "10.1.0.0/16" if it's a network, ["10.1.0.1", "192.168.0.0/16"] if the source is restricted.
+func reverseVirtualAddr(addr string, body map[string]interface{}) interface{} {
+	ipv6 := strings.Contains(addr, ":")
+	var dest interface{} = addr
+	if mask, ok := body["mask"].(string); ok {
+		if bits := maskBits(mask); bits >= 0 && !(ipv6 && bits == 128) && !(!ipv6 && bits == 32) {
+			dest = fmt.Sprintf("%s/%d", addr, bits)
+		}
+	}
+	if src, ok := body["source"].(string); ok {
+		// any source, i.e. 0.0.0.0/0, is not a restriction.
+		if _, smask, err := splitAddress(src); err == nil && maskBits(smask) != 0 {
+			return []interface{}{dest, src}
+		}
+	}
+	return dest
+}
+
+func (rc *ReverseContext) reversePool(parent string, body map[string]interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{
+		"class": "Pool",
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/pool", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "description":
+			obj["remark"] = v
+		case "monitor":
+			monitors, min := rc.reverseMonitorRule(parent, v.(string))
+			if len(monitors) > 0 {
+				obj["monitors"] = monitors
+				obj["minimumMonitors"] = min
+			}
+		case "members":
+			members, err := rc.reversePoolMembers(parent, v.([]interface{}))
+			if err != nil {
+				return err
+			}
+			obj["members"] = members
+		default:
+			rc.reverseProperties("ltm/pool", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// reversePoolMembers converts the REST pool members, the ones with the same
+// properties except the address are merged as one as3 pool member.
+func (rc *ReverseContext) reversePoolMembers(parent string, items []interface{}) ([]interface{}, error) {
+	members := []interface{}{}
+	merged := map[string]map[string]interface{}{}
+	for i, item := range items {
+		body, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("pool member %d should be an object", i)
+		}
+		name, ok := body["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("pool member %d requires name", i)
+		}
+		member := map[string]interface{}{}
+		if strings.HasPrefix(name, "/Common/") && !strings.HasPrefix(parent, "/Common/") {
+			member["shareNodes"] = true
+		}
+		node, port, err := splitDestination(name)
+		if err != nil {
+			return nil, fmt.Errorf("pool member %d: %s", i, err.Error())
+		}
+		member["servicePort"] = port
+		node = strings.TrimPrefix(node, "/Common/")
+		address := node
+		if addr, ok := body["address"].(string); ok {
+			address = addr
+		}
+
+		for k, v := range body {
+			if err := checkRestProperty("ltm/pool/members", k, v); err != nil {
+				return nil, fmt.Errorf("pool member %d: %s", i, err.Error())
+			}
+			switch k {
+			case "name", "address", "fullPath", "partition", "kind", "selfLink", "generation":
+			case "session", "state", "fqdn":
+			case "rateLimit":
+				if v == "disabled" {
+					member["rateLimit"] = -1
+				} else {
+					rc.reverseProperties("ltm/pool/members", map[string]interface{}{k: v}, member)
+				}
+			case "monitor":
+				if v != "default" {
+					monitors, min := rc.reverseMonitorRule(parent, v.(string))
+					member["monitors"] = monitors
+					member["minimumMonitors"] = min
+				}
+			default:
+				rc.reverseProperties("ltm/pool/members", map[string]interface{}{k: v}, member)
+			}
+		}
+		switch {
+		case body["state"] == "user-down":
+			member["adminState"] = "offline"
+		case body["session"] == "user-disabled":
+			member["adminState"] = "disable"
+		default:
+			member["adminState"] = "enable"
+		}
+
+		if fqdn, ok := body["fqdn"].(map[string]interface{}); ok {
+			rc.reverseFqdnMember(parent, node, fqdn, member)
+			members = append(members, member)
+			continue
+		}
+
+		// nodes named other than the address are from 'servers'.
+		field, server := "serverAddresses", interface{}(address)
+		if node != address {
+			field, server = "servers", map[string]interface{}{"name": node, "address": address}
+		}
+		bkey, _ := json.Marshal(member)
+		mkey := field + string(bkey)
+		if m, f := merged[mkey]; f {
+			m[field] = append(m[field].([]interface{}), server)
+			continue
+		}
+		merged[mkey] = member
+		member[field] = []interface{}{server}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// reverseFqdnMember fills the fqdn properties of the member from the FQDN node, in the partition or /Common.
+func (rc *ReverseContext) reverseFqdnMember(parent, node string, fqdn, member map[string]interface{}) {
+	member["addressDiscovery"] = "fqdn"
+	member["hostname"] = node
+	if hostname, ok := fqdn["tmName"].(string); ok {
+		member["hostname"] = hostname
+	}
+	partition := strings.Split(strings.TrimPrefix(parent, "/"), "/")[0]
+	if member["shareNodes"] == true {
+		partition = "Common"
+	}
+	pobj, _ := rc.restobjs[partition].(map[string]interface{})
+	root, _ := pobj[""].(map[string]interface{})
+	nobj, _ := root["ltm/node/"+node].(map[string]interface{})
+	nfqdn, ok := nobj["fqdn"].(map[string]interface{})
+	if !ok {
+		// the node is not given, i.e. created by others.
+		member["autoPopulate"] = fqdn["autopopulate"] == "enabled"
+		return
+	}
+	for k, v := range nfqdn {
+		switch k {
+		case "tmName":
+		case "interval":
+			if v == "ttl" {
+				member["queryInterval"] = 0
+			} else {
+				member["queryInterval"] = v
+			}
+		default:
+			rc.reverseProperties("ltm/node/fqdn", map[string]interface{}{k: v}, member)
+		}
+	}
+}
+
+// reverseMonitorRule parses the monitor rule of pools and pool members, i.e.
+//
+//	"min 1 of /Common/http /T/A/mon" or "/Common/http and /T/A/mon"
+func (rc *ReverseContext) reverseMonitorRule(parent, rule string) ([]interface{}, interface{}) {
+	rule = strings.TrimSpace(rule)
+	var min interface{} = "all"
+	names := []string{}
+	if strings.HasPrefix(rule, "min ") {
+		fields := strings.Fields(rule)
+		if len(fields) > 3 {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				min = n
+			}
+			names = fields[3:]
+		}
+	} else if rule != "" {
+		for _, n := range strings.Split(rule, " and ") {
+			names = append(names, strings.TrimSpace(n))
+		}
+	}
+	monitors := []interface{}{}
+	for _, n := range names {
+		if builtin := strings.TrimPrefix(n, "/Common/"); builtinMonitors[builtin] {
+			if builtin == "gateway_icmp" {
+				builtin = "icmp"
+			}
+			monitors = append(monitors, builtin)
+		} else {
+			monitors = append(monitors, rc.reverseRef(parent, n))
+		}
+	}
+	return monitors, min
+}
+
+func (rc *ReverseContext) reverseMonitor(parent, t string, body, app map[string]interface{}) (map[string]interface{}, error) {
+	if t == "gateway-icmp" {
+		t = "icmp"
+	}
+	obj := map[string]interface{}{
+		"class":       "Monitor",
+		"monitorType": t,
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/monitor", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "destination":
+			dest := v.(string)
+			if t == "icmp" {
+				if dest != "*" {
+					obj["targetAddress"] = dest
+				}
+				return nil
+			}
+			addr, port, err := splitDestination(dest)
+			if err != nil {
+				return err
+			}
+			if addr != "*" {
+				obj["targetAddress"] = addr
+			}
+			if port != 0 {
+				obj["targetPort"] = port
+			}
+		case "send", "recv", "recvDisable":
+			str := strings.ReplaceAll(v.(string), "\\r", "\r")
+			str = strings.ReplaceAll(str, "\\n", "\n")
+			rc.reverseProperties("ltm/monitor", map[string]interface{}{k: str}, obj)
+		case "password":
+			obj["passphrase"] = reverseSecret(v.(string))
+		case "cert":
+			obj["clientCertificate"] = rc.reverseCertificate(parent, body, app)
+		case "key":
+		case "sslProfile":
+			obj["clientTLS"] = rc.reverseRef(parent, v.(string))
+		case "run":
+			// the script uploaded along with the monitor, see convertExternalMonitor.
+			if _, found := rc.lookup("sys/file/external-monitor", parent, v.(string)); found {
+				folder, name := splitPath(parent, v.(string))
+				if content, ok := rc.reverseFile(folder + "/" + name).(string); ok {
+					obj["script"] = content
+					return nil
+				}
+			}
+			obj["pathname"] = v
+		case "apiRawValues":
+			vars := map[string]interface{}{}
+			for rk, rv := range v.(map[string]interface{}) {
+				if n := strings.TrimPrefix(rk, "userDefined "); n != rk {
+					vars[n] = rv
+				}
+			}
+			obj["environmentVariables"] = vars
+		case "adaptiveDivergenceType":
+			obj[k] = v
+		case "adaptiveDivergenceValue":
+			// see convertAdaptiveMonitor.
+			if body["adaptiveDivergenceType"] == "absolute" {
+				obj["adaptiveDivergenceMilliseconds"] = v
+			} else {
+				obj["adaptiveDivergencePercentage"] = v
+			}
+		case "failures", "failureInterval", "responseTime", "retryTime":
+			// inband monitor properties, not in rest.properties.json.
+			obj[k] = v
+		default:
+			rc.reverseProperties("ltm/monitor", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (rc *ReverseContext) reverseProfile(parent, kind, name string, body, folder, app map[string]interface{}) (map[string]interface{}, error) {
+	cls, f := profileClasses[kind]
+	if !f {
+		return nil, newDiagError(CodeUnsupported, "not supported profile type: %s", kind)
+	}
+	obj := map[string]interface{}{
+		"class": cls,
+	}
+	switch kind {
+	case "client-ssl":
+		certificates := []interface{}{}
+		names := []string{name}
+		for k := range folder {
+			if m := indexedPattern.FindStringSubmatch(k); m != nil && m[1] == "ltm/profile/client-ssl/"+name {
+				names = append(names, strings.TrimPrefix(k, "ltm/profile/client-ssl/"))
+			}
+		}
+		sort.Strings(names[1:])
+		for _, n := range names {
+			p, ok := folder["ltm/profile/client-ssl/"+n].(map[string]interface{})
+			if !ok {
+				return nil, newDiagError(CodeMalformed, "ltm/profile/client-ssl/%s should be an object", n)
+			}
+			certificate := map[string]interface{}{}
+			if sn, ok := p["serverName"].(string); ok && sn != "none" {
+				certificate["matchToSNI"] = sn
+			}
+			if _, ok := p["cert"].(string); ok {
+				certificate["certificate"] = rc.reverseCertificate(parent, p, app)
+			}
+			certificates = append(certificates, certificate)
+		}
+		obj["certificates"] = certificates
+	case "server-ssl":
+		if _, ok := body["cert"].(string); ok {
+			obj["clientCertificate"] = rc.reverseCertificate(parent, body, app)
+		}
+	}
+
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/profile", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "cert", "key", "chain", "passphrase", "serverName", "sniDefault":
+		case "authenticate":
+			value := strings.ReplaceAll(v.(string), "once", "one-time")
+			value = strings.ReplaceAll(value, "always", "every-time")
+			obj["authenticationFrequency"] = value
+		case "caFile":
+			if v == "/Common/ca-bundle.crt" && kind == "server-ssl" {
+				obj["trustCA"] = "generic"
+			} else if kind == "server-ssl" {
+				obj["trustCA"] = rc.reverseRef(parent, v.(string))
+			} else {
+				obj["authenticationTrustCA"] = map[string]interface{}{"bigip": v}
+			}
+		default:
+			rc.reverseProperties("ltm/profile/"+kind, map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// reverseCertificate creates the Certificate class in app from the cert, key and chain of the ssl profile,
+// with the uploaded contents if found, or referring to the files on BIG-IP. The certificate name is returned.
+func (rc *ReverseContext) reverseCertificate(parent string, profile, app map[string]interface{}) string {
+	cert := profile["cert"].(string)
+	folder, name := splitPath(parent, cert)
+	name = strings.TrimSuffix(name, ".crt")
+	certificate := map[string]interface{}{
+		"class": "Certificate",
+	}
+	for _, item := range [][2]string{{"cert", "certificate"}, {"key", "privateKey"}, {"chain", "chainCA"}} {
+		path, ok := profile[item[0]].(string)
+		if !ok || path == "" {
+			continue
+		}
+		certificate[item[1]] = rc.reverseFile(path)
+	}
+	if pass, ok := profile["passphrase"].(string); ok {
+		certificate["passphrase"] = reverseSecret(pass)
+	}
+	if folder == parent {
+		app[name] = certificate
+		return name
+	}
+	return folder + "/" + name
+}
+
+// reverseFile returns the uploaded content of the file, or refers to it on BIG-IP.
+func (rc *ReverseContext) reverseFile(path string) interface{} {
+	folder, name := splitPath("", path)
+	segs := strings.Split(strings.TrimPrefix(folder, "/"), "/")
+	if len(segs) == 2 {
+		uploaded := fmt.Sprintf("_%s__%s__%s", segs[0], segs[1], name)
+		if f, found := rc.lookup("shared/file-transfer/uploads", folder, "/"+segs[0]+"/"+segs[1]+"/"+uploaded); found {
+			if fobj, ok := f.(map[string]interface{}); ok && fobj["content"] != nil {
+				return fobj["content"]
+			}
+		}
+	}
+	return map[string]interface{}{"bigip": path}
+}
+
+func (rc *ReverseContext) reversePersist(t string, body map[string]interface{}) (map[string]interface{}, error) {
+	method := t
+	switch t {
+	case "dest-addr":
+		method = "destination-address"
+	case "ssl":
+		method = "tls-session-id"
+	case "sip":
+		method = "sip-info"
+	case "source-addr":
+		method = "source-address"
+	}
+	obj := map[string]interface{}{
+		"class":             "Persist",
+		"persistenceMethod": method,
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/persistence", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "timeout":
+			if v == "indefinite" {
+				obj["duration"] = 0
+			} else {
+				rc.reverseProperties("ltm/persistence", map[string]interface{}{k: v}, obj)
+			}
+		case "password":
+			obj["passphrase"] = reverseSecret(v.(string))
+		default:
+			rc.reverseProperties("ltm/persistence", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (rc *ReverseContext) reverseVirtualAddress(body map[string]interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{
+		"class": "Service_Address",
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/virtual-address", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "icmpEcho", "routeAdvertisement":
+			obj[k] = strings.ReplaceAll(v.(string), "abled", "able")
+		case "address":
+			obj["virtualAddress"] = v
+		default:
+			rc.reverseProperties("ltm/virtual-address", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (rc *ReverseContext) reverseDataGroup(parent, kind string, body map[string]interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{
+		"class":       "Data_Group",
+		"storageType": kind,
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/data-group", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name":
+		case "type":
+			obj["keyDataType"] = v
+		case "externalFileName":
+			f, found := rc.lookup("sys/file/data-group", parent, v.(string))
+			if !found {
+				return fmt.Errorf("data group file %s not found", v)
+			}
+			fobj, ok := f.(map[string]interface{})
+			if !ok {
+				return newDiagError(CodeMalformed, "data group file %s should be an object", v)
+			}
+			obj["externalFilePath"] = fobj["sourcePath"]
+		case "records":
+			records := []interface{}{}
+			for _, item := range v.([]interface{}) {
+				r := item.(map[string]interface{})
+				record := map[string]interface{}{"key": r["name"]}
+				if data, f := r["data"]; f {
+					record["value"] = data
+				}
+				records = append(records, record)
+			}
+			obj["records"] = records
+		default:
+			rc.reverseProperties("ltm/data-group/internal", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (rc *ReverseContext) reversePolicy(parent string, body map[string]interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{
+		"class": "Endpoint_Policy",
+	}
+	if err := eachProperty(body, func(k string, v interface{}) error {
+		if err := checkRestProperty("ltm/policy", k, v); err != nil {
+			return err
+		}
+		switch k {
+		case "name", "legacy", "requires", "controls", "status", "references":
+		case "strategy":
+			s := v.(string)
+			if strings.HasPrefix(s, "/Common/") && strings.Count(s, "/") == 2 {
+				obj["strategy"] = strings.TrimPrefix(s, "/Common/")
+			} else {
+				obj["strategy"] = rc.reverseRef(parent, s)
+			}
+		case "rules":
+			items := v.([]interface{})
+			rules := make([]interface{}, len(items))
+			for i, item := range items {
+				rule, err := rc.reversePolicyRule(parent, item.(map[string]interface{}))
+				if err != nil {
+					return fmt.Errorf("policy rule %d: %s", i, err.Error())
+				}
+				// rules are ordered by the ordinal.
+				ordinal := i
+				if n, ok := item.(map[string]interface{})["ordinal"].(float64); ok && int(n) < len(items) {
+					ordinal = int(n)
+				} else if n, ok := item.(map[string]interface{})["ordinal"].(int); ok && n < len(items) {
+					ordinal = n
+				}
+				if rules[ordinal] != nil {
+					ordinal = i
+				}
+				rules[ordinal] = rule
+			}
+			obj["rules"] = rules
+		default:
+			rc.reverseProperties("ltm/policy", map[string]interface{}{k: v}, obj)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (rc *ReverseContext) reversePolicyRule(parent string, body map[string]interface{}) (map[string]interface{}, error) {
+	rule := map[string]interface{}{}
+	for k, v := range body {
+		if err := checkRestProperty("ltm/policy/rules", k, v); err != nil {
+			return nil, err
+		}
+		switch k {
+		case "ordinal":
+		case "conditions":
+			conditions := []interface{}{}
+			for i, item := range v.([]interface{}) {
+				condition, err := rc.reversePolicyCondition(parent, item.(map[string]interface{}))
+				if err != nil {
+					return nil, fmt.Errorf("condition %d: %s", i, err.Error())
+				}
+				conditions = append(conditions, condition)
+			}
+			rule["conditions"] = conditions
+		case "actions":
+			actions := []interface{}{}
+			for i, item := range v.([]interface{}) {
+				action, err := rc.reversePolicyAction(parent, item.(map[string]interface{}))
+				if err != nil {
+					return nil, fmt.Errorf("action %d: %s", i, err.Error())
+				}
+				actions = append(actions, action)
+			}
+			rule["actions"] = actions
+		default:
+			rc.reverseProperties("ltm/policy/rules", map[string]interface{}{k: v}, rule)
+		}
+	}
+	return rule, nil
+}
+
+// reversePolicyCondition is the reverse of convertPolicyCondition.
+func (rc *ReverseContext) reversePolicyCondition(parent string, body map[string]interface{}) (map[string]interface{}, error) {
+	condition := map[string]interface{}{}
+	for t, selectors := range map[string][]string{
+		"httpUri":      {"all", "path", "queryString", "host", "port", "scheme", "extension", "pathSegment", "queryParameter"},
+		"httpHeader":   {"all"},
+		"httpCookie":   {"all"},
+		"httpMethod":   {"all"},
+		"sslExtension": {"serverName", "npn", "alpn"},
+		"tcp":          {"address", "port"},
+	} {
+		if body[t] != true {
+			continue
+		}
+		condition["type"] = t
+		if n, f := body["tmName"]; f {
+			condition["name"] = n
+		}
+		for _, sel := range selectors {
+			if body[sel] == true {
+				condition[sel] = rc.reversePolicyCompare(parent, body)
+				break
+			}
+		}
+	}
+	if _, f := condition["type"]; !f {
+		return nil, newDiagError(CodeUnsupported, "unsupported policy condition: %v", body)
+	}
+	if event := policyEvent(body); event != "" && event != "request" {
+		condition["event"] = event
+	}
+	if index, f := body["index"]; f {
+		condition["index"] = index
+	}
+	return condition, nil
+}
+
+// negatedOperands are the as3 operands of the policy conditions with 'not', the numeric ones have no negation.
+var negatedOperands = map[string]string{
+	"equals":      "does-not-equal",
+	"starts-with": "does-not-start-with",
+	"ends-with":   "does-not-end-with",
+	"contains":    "does-not-contain",
+	"exists":      "does-not-exist",
+	"match":       "does-not-match",
+}
+
+// reversePolicyCompare is the reverse of convertPolicyCompare.
+func (rc *ReverseContext) reversePolicyCompare(parent string, body map[string]interface{}) map[string]interface{} {
+	compare := map[string]interface{}{}
+	operand := "equals"
+	for o, as3o := range map[string]string{
+		"equals":         "equals",
+		"startsWith":     "starts-with",
+		"endsWith":       "ends-with",
+		"contains":       "contains",
+		"exists":         "exists",
+		"match":          "match",
+		"less":           "less",
+		"greater":        "greater",
+		"lessOrEqual":    "less-or-equal",
+		"greaterOrEqual": "greater-or-equal",
+	} {
+		if body[o] == true {
+			operand = as3o
+		}
+	}
+	if body["not"] == true {
+		if negated, f := negatedOperands[operand]; f {
+			operand = negated
+		}
+	}
+	compare["operand"] = operand
+	if values, f := body["values"]; f {
+		compare["values"] = values
+	}
+	if dg, ok := body["datagroup"].(string); ok {
+		compare["datagroup"] = rc.reverseRef(parent, dg)
+	}
+	for _, o := range []string{"equals", "startsWith", "endsWith", "contains"} {
+		if body[o] == true && body["caseInsensitive"] != true {
+			compare["caseSensitive"] = true
+		}
+	}
+	return compare
+}
+
+// reversePolicyAction is the reverse of convertPolicyAction.
+func (rc *ReverseContext) reversePolicyAction(parent string, body map[string]interface{}) (map[string]interface{}, error) {
+	action := map[string]interface{}{}
+	switch {
+	case body["forward"] == true:
+		action["type"] = "forward"
+		sel := map[string]interface{}{}
+		for _, target := range []string{"pool", "virtual", "snatpool"} {
+			if v, ok := body[target].(string); ok {
+				if target == "virtual" {
+					target = "service"
+				}
+				sel[target] = rc.reverseRef(parent, v)
+			}
+		}
+		for _, target := range []string{"node", "snat"} {
+			if v, f := body[target]; f {
+				sel[target] = v
+			}
+		}
+		action["select"] = sel
+	case body["drop"] == true:
+		action["type"] = "drop"
+	case body["httpReply"] == true && body["redirect"] == true:
+		action["type"] = "httpRedirect"
+		action["location"] = body["location"]
+		if code, f := body["code"]; f {
+			action["code"] = code
+		}
+	case body["httpHeader"] == true, body["httpCookie"] == true:
+		t := "httpHeader"
+		if body["httpCookie"] == true {
+			t = "httpCookie"
+		}
+		action["type"] = t
+		for _, op := range []string{"insert", "replace", "remove"} {
+			if body[op] != true {
+				continue
+			}
+			opobj := map[string]interface{}{}
+			if n, f := body["tmName"]; f {
+				opobj["name"] = n
+			}
+			if v, f := body["value"]; f {
+				opobj["value"] = v
+			}
+			action[op] = opobj
+		}
+	case body["httpUri"] == true:
+		action["type"] = "httpUri"
+		replace := map[string]interface{}{}
+		for _, f := range []string{"value", "path", "queryString"} {
+			if v, found := body[f]; found {
+				replace[f] = v
+			}
+		}
+		action["replace"] = replace
+	case body["log"] == true:
+		action["type"] = "log"
+		write := map[string]interface{}{}
+		for k, v := range body {
+			if v == true || k == "name" {
+				continue
+			}
+			write[k] = v
+		}
+		action["write"] = write
+	default:
+		return nil, newDiagError(CodeUnsupported, "unsupported policy action: %v", body)
+	}
+	if event := policyEvent(body); event != "" && event != "request" {
+		action["event"] = event
+	}
+	return action, nil
+}
+
+// policyEvent finds the event of the policy condition or action, i.e. "sslClientHello" -> "ssl-client-hello".
+func policyEvent(body map[string]interface{}) string {
+	for _, e := range []string{"request", "response", "ssl-client-hello", "ssl-server-hello",
+		"proxy-request", "proxy-response", "proxy-connect", "client-accepted", "server-connected", "ssl-client-serverhello-send"} {
+		if body[camelCase(e)] == true {
+			return e
+		}
+	}
+	return ""
+}
+
+// reverseProperties sets the as3 properties of obj from the REST properties of body,
+// the ones not found in rest.properties.json are ignored.
+func (rc *ReverseContext) reverseProperties(kind string, body, obj map[string]interface{}) {
+	for k, v := range body {
+		as3name, prop, f := rc.as3name(kind, k)
+		if !f {
+			continue
+		}
+		obj[as3name] = reverseValue(prop, v)
+	}
+}
+
+// as3name finds the as3 property name of the REST property restname, i.e. "ltm/virtual", "connectionLimit" -> "maxConnections".
+// The as3 names are preferred to the tmsh ones, which are converted to camel case.
+func (p *Parser) as3name(kind, restname string) (string, Property, bool) {
+	props, f := p.properties[kind]
+	if !f {
+		return "", Property{}, false
+	}
+	names := []string{}
+	for n, prop := range props {
+		if prop.RestName == restname {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return "", Property{}, false
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ki, kj := strings.Contains(names[i], "-"), strings.Contains(names[j], "-")
+		if ki != kj {
+			return !ki
+		}
+		return names[i] < names[j]
+	})
+	return camelCase(names[0]), props[names[0]], true
+}
+
+func reverseValue(prop Property, v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		if prop.Truth != "" && s == prop.Truth {
+			return true
+		}
+		if prop.Falsehood != "" && s == prop.Falsehood {
+			return false
+		}
+		if prop.IntToString {
+			if n, err := strconv.Atoi(s); err == nil {
+				return n
+			}
+		}
+	}
+	return v
+}
+
+func reverseSecret(s string) map[string]interface{} {
+	return map[string]interface{}{
+		"ciphertext": base64.StdEncoding.EncodeToString([]byte(s)),
+		// {"alg":"dir","enc":"none"}
+		"protected": "eyJhbGciOiJkaXIiLCJlbmMiOiJub25lIn0",
+	}
+}
+
+// reverseRef refers to the REST object of full path or name from parent, as the as3 pointer:
+// objects within restobjs are referred by 'use', others by 'bigip'.
+func (rc *ReverseContext) reverseRef(parent, ref string) interface{} {
+	if !strings.HasPrefix(ref, "/") {
+		return map[string]interface{}{"use": ref}
+	}
+	if strings.HasPrefix(ref, parent+"/") {
+		return map[string]interface{}{"use": strings.TrimPrefix(ref, parent+"/")}
+	}
+	if _, f := rc.lookup("", parent, ref); f {
+		return map[string]interface{}{"use": ref}
+	}
+	return map[string]interface{}{"bigip": ref}
+}
+
+// lookup finds the REST object referred by ref from parent, kind "" matches any kind.
+func (rc *ReverseContext) lookup(kind, parent, ref string) (interface{}, bool) {
+	folder, name := splitPath(parent, ref)
+	segs := strings.Split(strings.TrimPrefix(folder, "/"), "/")
+	if len(segs) != 2 {
+		return nil, false
+	}
+	partition, ok := rc.restobjs[segs[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	objs, ok := partition[segs[1]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	for k, v := range objs {
+		if (kind == "" || strings.HasPrefix(k, kind+"/")) && strings.HasSuffix(k, "/"+name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// profileKind decides the kind of the profile, i.e. "client-ssl", from the REST objects or the well known ones.
+func (rc *ReverseContext) profileKind(parent, ref string) (string, bool) {
+	if kind, f := wellKnownProfileKinds[ref]; f {
+		return kind, true
+	}
+	for kind := range profileClasses {
+		if _, f := rc.lookup("ltm/profile/"+kind, parent, ref); f {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// restPropertyTypes are the types of the REST properties read by the reverse converters, keyed by "kind/property":
+// "string", "object", "strings" for an array of strings, "objects" for an array of objects,
+// or "names" for an array of objects with the name.
+var restPropertyTypes = map[string]string{
+	"ltm/virtual/destination":                "string",
+	"ltm/virtual/pool":                       "string",
+	"ltm/virtual/profiles":                   "names",
+	"ltm/virtual/persist":                    "names",
+	"ltm/virtual/rules":                      "strings",
+	"ltm/virtual/policies":                   "names",
+	"ltm/virtual/sourceAddressTranslation":   "object",
+	"ltm/pool/monitor":                       "string",
+	"ltm/pool/members/monitor":               "string",
+	"ltm/monitor/destination":                "string",
+	"ltm/monitor/send":                       "string",
+	"ltm/monitor/recv":                       "string",
+	"ltm/monitor/recvDisable":                "string",
+	"ltm/monitor/password":                   "string",
+	"ltm/monitor/cert":                       "string",
+	"ltm/monitor/sslProfile":                 "string",
+	"ltm/monitor/run":                        "string",
+	"ltm/monitor/apiRawValues":               "object",
+	"ltm/profile/authenticate":               "string",
+	"ltm/profile/caFile":                     "string",
+	"ltm/persistence/password":               "string",
+	"ltm/virtual-address/icmpEcho":           "string",
+	"ltm/virtual-address/routeAdvertisement": "string",
+	"ltm/data-group/externalFileName":        "string",
+	"ltm/data-group/records":                 "names",
+	"ltm/policy/strategy":                    "string",
+	"ltm/policy/rules":                       "objects",
+	"ltm/policy/rules/conditions":            "objects",
+	"ltm/policy/rules/actions":               "objects",
+}
+
+// checkRestProperty checks the type of the REST property k of kind, see restPropertyTypes.
+func checkRestProperty(kind, k string, v interface{}) error {
+	t, f := restPropertyTypes[kind+"/"+k]
+	if !f {
+		return nil
+	}
+	switch t {
+	case "string":
+		if _, ok := v.(string); !ok {
+			return newDiagError(CodeInvalidValue, "%s should be a string: %v", k, v)
+		}
+	case "object":
+		if _, ok := v.(map[string]interface{}); !ok {
+			return newDiagError(CodeInvalidValue, "%s should be an object: %v", k, v)
+		}
+	default:
+		items, ok := v.([]interface{})
+		if !ok {
+			return newDiagError(CodeInvalidValue, "%s should be an array: %v", k, v)
+		}
+		for i, item := range items {
+			if t == "strings" {
+				if _, ok := item.(string); !ok {
+					return newDiagError(CodeInvalidValue, "%s item %d should be a string: %v", k, i, item)
+				}
+				continue
+			}
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return newDiagError(CodeInvalidValue, "%s item %d should be an object: %v", k, i, item)
+			}
+			if _, ok := obj["name"].(string); !ok && t == "names" {
+				return newDiagError(CodeInvalidValue, "%s item %d requires the name: %v", k, i, item)
+			}
+		}
+	}
+	return nil
+}
+
+// splitDestination splits the destination, i.e. "/T/10.1.1.1:80" or "2001::1.80", to address and port.
+func splitDestination(dest string) (string, int, error) {
+	if i := strings.LastIndex(dest, "/"); i >= 0 {
+		dest = dest[i+1:]
+	}
+	sep := ":"
+	if strings.Count(dest, ":") > 1 {
+		sep = "."
+	}
+	i := strings.LastIndex(dest, sep)
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid destination: %s", dest)
+	}
+	addr, port := dest[:i], dest[i+1:]
+	if port == "*" || port == "any" {
+		return addr, 0, nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port of destination: %s", dest)
+	}
+	return addr, n, nil
+}
+
+func unrenamePersist(t string) string {
+	switch t {
+	case "dest_addr":
+		return "destination-address"
+	case "ssl":
+		return "tls-session-id"
+	case "sip_info":
+		return "sip-info"
+	case "source_addr":
+		return "source-address"
+	default:
+		return t
+	}
+}
+
+var (
+	// profileClasses maps the REST profile kinds to the as3 classes.
+	profileClasses = map[string]string{
+		"http":        "HTTP_Profile",
+		"tcp":         "TCP_Profile",
+		"udp":         "UDP_Profile",
+		"fastl4":      "L4_Profile",
+		"one-connect": "Multiplex_Profile",
+		"ftp":         "FTP_Profile",
+		"client-ssl":  "TLS_Server",
+		"server-ssl":  "TLS_Client",
+	}
+	// profileProperties maps the REST profile kinds to the as3 service properties.
+	profileProperties = map[string]string{
+		"http":        "profileHTTP",
+		"tcp":         "profileTCP",
+		"udp":         "profileUDP",
+		"fastl4":      "profileL4",
+		"one-connect": "profileMultiplex",
+		"ftp":         "profileFTP",
+		"client-ssl":  "serverTLS",
+		"server-ssl":  "clientTLS",
+		"sctp":        "profileSCTP",
+	}
+	wellKnownProfileKinds = map[string]string{
+		"/Common/http":               "http",
+		"/Common/tcp":                "tcp",
+		"/Common/f5-tcp-progressive": "tcp",
+		"/Common/udp":                "udp",
+		"/Common/fastL4":             "fastl4",
+		"/Common/oneconnect":         "one-connect",
+		"/Common/ftp":                "ftp",
+		"/Common/clientssl":          "client-ssl",
+		"/Common/serverssl":          "server-ssl",
+		"/Common/sctp":               "sctp",
+	}
+	// wellKnownProfiles are the profiles set by the as3 aliases, see convertVirtual.
+	wellKnownProfiles = map[string]string{
+		"/Common/http":               "basic",
+		"/Common/f5-tcp-progressive": "normal",
+		"/Common/fastL4":             "basic",
+	}
+	builtinMonitors = map[string]bool{
+		"http":          true,
+		"https":         true,
+		"tcp":           true,
+		"udp":           true,
+		"icmp":          true,
+		"gateway_icmp":  true,
+		"tcp_half_open": true,
+		"http2":         true,
+		"https_443":     true,
+	}
+)
@@ -0,0 +1,230 @@
+package as3parsing
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// adc wraps the applications of tenant T into an as3 body.
+func adc(apps string) map[string]interface{} {
+	body := map[string]interface{}{}
+	decl := `{"class": "AS3", "declaration": {"class": "ADC", "schemaVersion": "3.0.0", "T": {"class": "Tenant", ` + apps + `}}}`
+	if err := json.Unmarshal([]byte(decl), &body); err != nil {
+		panic(err)
+	}
+	return body
+}
+
+func TestRestToAS3RoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		apps string
+		// classes are the reversed classes of application A, checked if set.
+		classes map[string]string
+	}{
+		{
+			name: "http service with string pointers",
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_HTTP", "virtualAddresses": ["10.0.0.1"], "pool": "web", "iRules": ["r1"]},
+				"web": {"class": "Pool", "monitors": ["http"],
+					"members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1", "10.1.0.2"]}]},
+				"r1": {"class": "iRule", "iRule": "when HTTP_REQUEST { }"}
+			}`,
+		},
+		{
+			name: "virtual addresses with snat self",
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_TCP", "virtualAddresses": ["10.0.0.3", "10.0.0.4", "10.0.0.5"], "virtualPort": 443,
+					"snat": "self", "pool": "web"},
+				"web": {"class": "Pool", "members": [{"servicePort": 443, "serverAddresses": ["10.1.0.5"]}]}
+			}`,
+			classes: map[string]string{"vs": "Service_TCP", "web": "Pool"},
+		},
+		{
+			name: "fqdn members",
+			apps: `"A": {"class": "Application",
+				"web": {"class": "Pool", "members": [
+					{"servicePort": 80, "addressDiscovery": "fqdn", "hostname": "www.example.com", "autoPopulate": true, "queryInterval": 0},
+					{"servicePort": 443, "addressDiscovery": "fqdn", "hostname": "api.example.com", "shareNodes": true, "addressFamily": "IPv6"}]}
+			}`,
+		},
+		{
+			name: "forwarding and sctp services",
+			apps: `"A": {"class": "Application",
+				"fw": {"class": "Service_Forwarding", "virtualAddresses": ["0.0.0.0/0"], "virtualPort": 0, "forwardingType": "L2"},
+				"sc": {"class": "Service_SCTP", "virtualAddresses": ["10.0.0.6"], "virtualPort": 3868}
+			}`,
+			classes: map[string]string{"fw": "Service_Forwarding", "sc": "Service_SCTP"},
+		},
+		{
+			name: "monitors",
+			apps: `"A": {"class": "Application",
+				"m1": {"class": "Monitor", "monitorType": "http", "send": "GET /\r\n", "receive": "200 OK",
+					"adaptive": true, "adaptiveDivergenceType": "absolute", "adaptiveDivergenceMilliseconds": 300},
+				"m2": {"class": "Monitor", "monitorType": "tcp-half-open", "targetPort": 8080},
+				"p": {"class": "Pool", "monitors": [{"use": "m1"}, {"use": "m2"}], "minimumMonitors": 1,
+					"members": [{"servicePort": 8080, "serverAddresses": ["10.1.0.3"]}]}
+			}`,
+		},
+		{
+			name: "endpoint policy",
+			apps: `"A": {"class": "Application",
+				"vs": {"class": "Service_HTTP", "virtualAddresses": ["10.0.0.2"], "policyEndpoint": "pol"},
+				"pool": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.4"]}]},
+				"pol": {"class": "Endpoint_Policy", "rules": [
+					{"name": "api", "conditions": [{"type": "httpUri", "path": {"operand": "does-not-start-with", "values": ["/api"]}}],
+						"actions": [{"type": "forward", "select": {"pool": {"use": "pool"}}}]},
+					{"name": "net", "conditions": [{"type": "tcp", "address": {"values": ["10.0.0.0/8"]}}],
+						"actions": [{"type": "drop"}]}
+				]}
+			}`,
+		},
+		{
+			name: "data group",
+			apps: `"A": {"class": "Application",
+				"dg": {"class": "Data_Group", "keyDataType": "integer", "records": [{"key": 1000000, "value": "large"}]}
+			}`,
+		},
+	}
+
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.TODO()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			restobjs, err := p.ParseAS3(ctx, adc(c.apps))
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			as3body, err := p.RestToAS3(ctx, restobjs)
+			if err != nil {
+				t.Fatalf("failed to reverse: %s", err)
+			}
+			reparsed, err := p.ParseAS3(ctx, as3body)
+			if err != nil {
+				t.Fatalf("failed to parse the reversed: %s", err)
+			}
+			if plan := DiffRest(restobjs, reparsed); len(plan) != 0 {
+				t.Errorf("the round trip changes:\n%s", plan)
+			}
+			if c.classes != nil {
+				app := as3body["declaration"].(map[string]interface{})["T"].(map[string]interface{})["A"].(map[string]interface{})
+				classes := map[string]string{}
+				for k, v := range app {
+					if obj, ok := v.(map[string]interface{}); ok {
+						classes[k], _ = obj["class"].(string)
+					}
+				}
+				got, _ := json.Marshal(classes)
+				want, _ := json.Marshal(c.classes)
+				if string(got) != string(want) {
+					t.Errorf("got classes %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRestToAS3Diagnostics(t *testing.T) {
+	cases := []struct {
+		name   string
+		folder map[string]interface{}
+		// diagnostics are "pointer code" of the expected diagnostics.
+		diagnostics []string
+	}{
+		{
+			name: "virtual destination",
+			folder: map[string]interface{}{
+				"ltm/virtual/vs": map[string]interface{}{"destination": 80},
+			},
+			diagnostics: []string{"/T/A/vs/destination invalid-value"},
+		},
+		{
+			name: "virtual profiles",
+			folder: map[string]interface{}{
+				"ltm/virtual/vs": map[string]interface{}{
+					"destination": "/T/10.0.0.1:80",
+					"profiles":    []interface{}{"/Common/http"},
+					"rules":       []interface{}{map[string]interface{}{"name": "/T/A/r"}},
+				},
+			},
+			diagnostics: []string{"/T/A/vs/profiles invalid-value", "/T/A/vs/rules invalid-value"},
+		},
+		{
+			name: "snat pool",
+			folder: map[string]interface{}{
+				"ltm/virtual/vs": map[string]interface{}{
+					"destination":              "/T/10.0.0.1:80",
+					"sourceAddressTranslation": map[string]interface{}{"type": "snat", "pool": 1},
+				},
+			},
+			diagnostics: []string{"/T/A/vs/sourceAddressTranslation invalid-value"},
+		},
+		{
+			name: "indexed virtual destination",
+			folder: map[string]interface{}{
+				"ltm/virtual/vs":    map[string]interface{}{"destination": "/T/10.0.0.1:80"},
+				"ltm/virtual/vs-1-": map[string]interface{}{"destination": nil},
+			},
+			diagnostics: []string{"/T/A/vs invalid-value"},
+		},
+		{
+			name: "pool monitor and members",
+			folder: map[string]interface{}{
+				"ltm/pool/web": map[string]interface{}{
+					"monitor": []interface{}{"/Common/http"},
+					"members": []interface{}{map[string]interface{}{"name": "/T/10.1.0.1:80", "monitor": 1}},
+				},
+			},
+			diagnostics: []string{"/T/A/web/members invalid-value", "/T/A/web/monitor invalid-value"},
+		},
+		{
+			name: "policy rules and data group records",
+			folder: map[string]interface{}{
+				"ltm/policy/pol":              map[string]interface{}{"rules": []interface{}{"r"}},
+				"ltm/data-group/internal/dg":  map[string]interface{}{"records": []interface{}{map[string]interface{}{"data": "x"}}},
+				"ltm/virtual-address/1.1.1.1": map[string]interface{}{"icmpEcho": true},
+			},
+			diagnostics: []string{
+				"/T/A/1.1.1.1/icmpEcho invalid-value",
+				"/T/A/dg/records invalid-value",
+				"/T/A/pol/rules invalid-value",
+			},
+		},
+		{
+			name: "malformed object",
+			folder: map[string]interface{}{
+				"ltm/monitor/http/m": "m",
+			},
+			diagnostics: []string{"/T/A/m malformed"},
+		},
+	}
+
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			restobjs := map[string]interface{}{"T": map[string]interface{}{"A": c.folder}}
+			_, err := p.RestToAS3(context.TODO(), restobjs)
+			ds, ok := err.(Diagnostics)
+			if !ok {
+				t.Fatalf("got error %v, want diagnostics", err)
+			}
+			got := []string{}
+			for _, d := range ds {
+				got = append(got, d.Pointer+" "+d.Code)
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(c.diagnostics, "\n") {
+				t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(c.diagnostics, "\n"))
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -61,7 +62,12 @@ func AS3ToRestProperties(as3PropFilePath, restPropFilePath string) error {
 	return ioutil.WriteFile(restPropFilePath, bLtmProps, 0644)
 }
 
-func addDefaults(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
+// AddDefaults returns the declaration of class ADC with the as3 default values added, in the parser's defaults mode.
+func (p *Parser) AddDefaults(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
+	return p.addDefaults(ctx, declaration)
+}
+
+func (p *Parser) addDefaults(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
 	slog := utils.LogFromContext(ctx)
 	defer utils.TimeIt(slog)("addDefaults timecost")
 	defer utils.TimeItToPrometheus()()
@@ -71,11 +77,14 @@ func addDefaults(ctx context.Context, declaration map[string]interface{}) (map[s
 		return rlt, fmt.Errorf("invalid declaration: not class ADC found")
 	}
 
-	if strings.HasPrefix(as3Service, bigip.URL) {
-		return addDefaultsViaBigip(ctx, declaration)
-	} else {
+	switch p.defaultsMode {
+	case DefaultsModeSchema:
+		return p.addDefaultsViaSchema(ctx, declaration)
+	case DefaultsModeBigip:
+		return p.addDefaultsViaBigip(ctx, declaration)
+	default:
 		declaration["scratch"] = "defaults-only"
-		return addDefaultsViaLocal(ctx, declaration)
+		return p.addDefaultsViaLocal(ctx, declaration)
 	}
 }
 
@@ -88,9 +97,18 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 	// 	Instead, we should do that in parse and convert functions.
 	// 	Here we just add logics for crossing multiple objs.
 
-	// move virtual-address to "" subfolder
+	// move virtual-address to "" subfolder, and virtual-address shared by 'shareAddresses' to /Common
 	relayVirtualAddress := func() {
-
+		// assemble all properties of the multiple virtual-address
+		assemble := func(vas map[string]interface{}, r string, body interface{}) {
+			if _, f := vas[r]; !f {
+				vas[r] = map[string]interface{}{}
+			}
+			for k, v := range body.(map[string]interface{}) {
+				vas[r].(map[string]interface{})[k] = v
+			}
+		}
+		commons := map[string]interface{}{}
 		for _, pobj := range restobjs {
 			vas := map[string]interface{}{}
 			folders := pobj.(map[string]interface{})
@@ -98,13 +116,10 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 				resources := fobj.(map[string]interface{})
 				for r, body := range resources {
 					if strings.HasPrefix(r, "ltm/virtual-address") {
-						if _, f := vas[r]; !f {
-							vas[r] = map[string]interface{}{}
-						}
-						// assemble all properties of the multiple virtual-address
-						jsonbody := body.(map[string]interface{})
-						for k, v := range jsonbody {
-							vas[r].(map[string]interface{})[k] = v
+						if body.(map[string]interface{})["partition"] == "Common" {
+							assemble(commons, r, body)
+						} else {
+							assemble(vas, r, body)
 						}
 						delete(resources, r)
 					}
@@ -112,9 +127,66 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 			}
 
 			if _, found := folders[""]; !found {
-				folders[""] = vas
+				folders[""] = map[string]interface{}{}
+			}
+			for r, body := range vas {
+				folders[""].(map[string]interface{})[r] = body
 			}
+		}
+		if len(commons) == 0 {
+			return
+		}
+		if _, found := restobjs["Common"]; !found {
+			restobjs["Common"] = map[string]interface{}{}
+		}
+		folders := restobjs["Common"].(map[string]interface{})
+		if _, found := folders[""]; !found {
+			folders[""] = map[string]interface{}{}
+		}
+		for r, body := range commons {
+			assemble(folders[""].(map[string]interface{}), r, body)
+		}
+	}
 
+	// move nodes to "" subfolder, and nodes shared by 'shareNodes' to /Common
+	relayNodes := func() {
+		commons := map[string]interface{}{}
+		for _, pobj := range restobjs {
+			folders := pobj.(map[string]interface{})
+			if _, found := folders[""]; !found {
+				folders[""] = map[string]interface{}{}
+			}
+			root := folders[""].(map[string]interface{})
+			for fname, fobj := range folders {
+				if fname == "" {
+					continue
+				}
+				resources := fobj.(map[string]interface{})
+				for r, body := range resources {
+					if !strings.HasPrefix(r, "ltm/node/") {
+						continue
+					}
+					if body.(map[string]interface{})["partition"] == "Common" {
+						commons[r] = body
+					} else {
+						root[r] = body
+					}
+					delete(resources, r)
+				}
+			}
+		}
+		if len(commons) == 0 {
+			return
+		}
+		if _, found := restobjs["Common"]; !found {
+			restobjs["Common"] = map[string]interface{}{}
+		}
+		folders := restobjs["Common"].(map[string]interface{})
+		if _, found := folders[""]; !found {
+			folders[""] = map[string]interface{}{}
+		}
+		for r, body := range commons {
+			folders[""].(map[string]interface{})[r] = body
 		}
 	}
 
@@ -154,6 +226,9 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 					for _, profobj := range oldpl.([]interface{}) {
 						profname := profobj.(map[string]interface{})["name"].(string)
 						pfp := utils.Keyname(pname, fname, profname)
+						if strings.HasPrefix(profname, "/") {
+							pfp = strings.TrimPrefix(profname, "/")
+						}
 						newpl = append(newpl, profobj)
 						if utils.Contains(tlsProfNames, pfp) {
 							ptn := fmt.Sprintf("%s-\\d+-", pfp)
@@ -163,6 +238,9 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 								if err == nil && matched {
 									pfparr := strings.Split(tlspfp, "/")
 									_, _, matchedprofname := pfparr[0], pfparr[1], pfparr[2]
+									if strings.HasPrefix(profname, "/") {
+										matchedprofname = "/" + tlspfp
+									}
 									newpl = append(newpl, map[string]interface{}{
 										"name": matchedprofname,
 									})
@@ -177,11 +255,12 @@ func customizeProperties(ctx context.Context, restobjs map[string]interface{}) e
 	}
 
 	relayVirtualAddress()
+	relayNodes()
 	addSNIProfiles()
 	return nil
 }
 
-func addDefaultsViaLocal(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
+func (p *Parser) addDefaultsViaLocal(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
 	slog := utils.LogFromContext(ctx)
 	rlt := map[string]interface{}{}
 	client := &http.Client{
@@ -196,7 +275,7 @@ func addDefaultsViaLocal(ctx context.Context, declaration map[string]interface{}
 	if err != nil {
 		return rlt, err
 	}
-	as3ep := fmt.Sprintf("%s/validate", as3Service)
+	as3ep := fmt.Sprintf("%s/validate", p.as3Service)
 	if status, response, err := utils.HttpRequest(
 		client, as3ep, "POST",
 		string(bsend),
@@ -215,11 +294,11 @@ func addDefaultsViaLocal(ctx context.Context, declaration map[string]interface{}
 			return fulldecl, nil
 		}
 	} else {
-		return rlt, fmt.Errorf("failed to add default values to declaration through %s: %d, %s", as3Service, status, string(response))
+		return rlt, fmt.Errorf("failed to add default values to declaration through %s: %d, %s", p.as3Service, status, string(response))
 	}
 }
 
-func addDefaultsViaBigip(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
+func (p *Parser) addDefaultsViaBigip(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
 	slog := utils.LogFromContext(ctx)
 	rlt := map[string]interface{}{}
 	client := &http.Client{
@@ -233,7 +312,7 @@ func addDefaultsViaBigip(ctx context.Context, declaration map[string]interface{}
 	// if show=expanded, the reference would be expanded to fullpath
 	// if declaration.scratch=='defaults-only', sometimes, it would reports error:
 	//    code: 422, message : "Cannot read property 'undefined' of undefined",
-	as3ep := fmt.Sprintf("%s/mgmt/shared/appsvcs/declare?show=full", as3Service)
+	as3ep := fmt.Sprintf("%s/mgmt/shared/appsvcs/declare?show=full", p.as3Service)
 	as3obj := map[string]interface{}{
 		"class":       "AS3",
 		"action":      "dry-run",
@@ -249,25 +328,15 @@ func addDefaultsViaBigip(ctx context.Context, declaration map[string]interface{}
 		string(bsend),
 		map[string]string{
 			"Content-Type":  "application/json",
-			"Authorization": bigip.Authorization,
+			"Authorization": p.bigip.Authorization,
 		},
 	); err != nil {
 		return rlt, err
 	} else if status == 200 {
-		var fullas3resp map[string]interface{}
 		slog.Debugf("addDefaults as3body: %s", response)
-		err := json.Unmarshal(response, &fullas3resp)
-		if err != nil {
-			return rlt, err
-		} else {
-			if fulldecl, f := fullas3resp["declaration"]; f {
-				return fulldecl.(map[string]interface{}), nil
-			} else {
-				return declaration, nil
-			}
-		}
+		return fullDeclFromAs3Response(response, declaration)
 	} else if status == 202 {
-		// TODO: as3 check turns to async mode, need to handle it
+		// as3 check turns to async mode, poll the task until the dry-run completes:
 		//   {
 		// 	"id": "94a17d6d-68e9-4ee9-aca4-ecf7fa54084b",
 		// 	"results": [
@@ -282,8 +351,169 @@ func addDefaultsViaBigip(ctx context.Context, declaration map[string]interface{}
 		// 	"declaration": {},
 		// 	"selfLink": "https://localhost/mgmt/shared/appsvcs/task/94a17d6d-68e9-4ee9-aca4-ecf7fa54084b"
 		//   }
-		return rlt, fmt.Errorf("as3 check turns to async mode, need to handle it: %s", string(response))
+		var taskresp map[string]interface{}
+		if err := json.Unmarshal(response, &taskresp); err != nil {
+			return rlt, err
+		}
+		taskId, _ := taskresp["id"].(string)
+		if selfLink, ok := taskresp["selfLink"].(string); ok && selfLink != "" {
+			sl := strings.Split(selfLink, "/")
+			taskId = sl[len(sl)-1]
+		}
+		if taskId == "" {
+			return rlt, fmt.Errorf("as3 check turns to async mode, but no task found: %s", string(response))
+		}
+		slog.Debugf("as3 check turns to async mode, waiting for task %s", taskId)
+		response, err := p.waitForAs3Task(ctx, client, taskId)
+		if err != nil {
+			return rlt, err
+		}
+		slog.Debugf("addDefaults as3body: %s", response)
+		return fullDeclFromAs3Response(response, declaration)
+	} else {
+		return rlt, fmt.Errorf("failed to add default values to declaration through %s: %d, %s", p.as3Service, status, string(response))
+	}
+}
+
+// waitForAs3Task polls /mgmt/shared/appsvcs/task/<taskId> with backoff until the task is no longer in progress,
+// it returns the last task response, or an error if the task fails or ctx is done.
+func (p *Parser) waitForAs3Task(ctx context.Context, client *http.Client, taskId string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, as3TaskTimeout)
+	defer cancel()
+
+	taskep := fmt.Sprintf("%s/mgmt/shared/appsvcs/task/%s?show=full", p.as3Service, taskId)
+	interval := as3TaskPollInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to wait for as3 task %s: %s", taskId, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		status, response, err := utils.HttpRequest(
+			client, taskep, "GET", "",
+			map[string]string{
+				"Authorization": p.bigip.Authorization,
+			},
+		)
+		if err != nil {
+			return nil, err
+		} else if status != 200 {
+			return nil, fmt.Errorf("failed to get as3 task %s: %d, %s", taskId, status, string(response))
+		}
+
+		var taskresp map[string]interface{}
+		if err := json.Unmarshal(response, &taskresp); err != nil {
+			return nil, err
+		}
+		inProgress := false
+		if results, ok := taskresp["results"].([]interface{}); ok {
+			for _, r := range results {
+				result, ok := r.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if result["message"] == "in progress" {
+					inProgress = true
+				} else if code, ok := result["code"].(float64); ok && code >= 400 {
+					return nil, fmt.Errorf("as3 task %s failed: %s", taskId, string(response))
+				}
+			}
+		}
+		if !inProgress {
+			return response, nil
+		}
+
+		interval *= 2
+		if interval > as3TaskPollMaxInterval {
+			interval = as3TaskPollMaxInterval
+		}
+	}
+}
+
+func fullDeclFromAs3Response(response []byte, declaration map[string]interface{}) (map[string]interface{}, error) {
+	var fullas3resp map[string]interface{}
+	err := json.Unmarshal(response, &fullas3resp)
+	if err != nil {
+		return map[string]interface{}{}, err
 	} else {
-		return rlt, fmt.Errorf("failed to add default values to declaration through %s: %d, %s", as3Service, status, string(response))
+		if fulldecl, ok := fullas3resp["declaration"].(map[string]interface{}); ok && len(fulldecl) > 0 {
+			return fulldecl, nil
+		} else {
+			return declaration, nil
+		}
 	}
 }
+
+func (p *Parser) addDefaultsViaSchema(ctx context.Context, declaration map[string]interface{}) (map[string]interface{}, error) {
+	slog := utils.LogFromContext(ctx)
+	rlt := map[string]interface{}{}
+	copieddecl, err := utils.DeepCopy(declaration)
+	if err != nil {
+		return rlt, err
+	}
+	fulldecl := copieddecl.(map[string]interface{})
+	if err := p.fillSchemaDefaults(fulldecl); err != nil {
+		return rlt, err
+	}
+	bdecl, _ := utils.MarshalNoEscaping(fulldecl)
+	slog.Debugf("addDefaults as3body: %s", bdecl)
+	return fulldecl, nil
+}
+
+// fillSchemaDefaults walks through the declaration and sets the default values
+// of the missing properties for each class object, and for the items of its object arrays.
+func (p *Parser) fillSchemaDefaults(obj map[string]interface{}) error {
+	cls, _ := obj["class"].(string)
+	if err := p.applySchemaDefaults(cls, obj); err != nil {
+		return err
+	}
+	if cls == "Monitor" {
+		if t, ok := obj["monitorType"].(string); ok {
+			if err := p.applySchemaDefaults(cls+"/"+t, obj); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range obj {
+		if v == nil {
+			continue
+		}
+		switch reflect.TypeOf(v).Kind().String() {
+		case "map":
+			if _, f := v.(map[string]interface{})["class"]; f {
+				if err := p.fillSchemaDefaults(v.(map[string]interface{})); err != nil {
+					return err
+				}
+			}
+		case "slice":
+			for _, item := range v.([]interface{}) {
+				if mitem, ok := item.(map[string]interface{}); ok {
+					if err := p.applySchemaDefaults(cls+"/"+k, mitem); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Parser) applySchemaDefaults(path string, obj map[string]interface{}) error {
+	defaults, f := p.defaults[path]
+	if !f {
+		return nil
+	}
+	for k, v := range defaults {
+		if _, f := obj[k]; f {
+			continue
+		}
+		// copy it, or all objects would share the same slice or map default value.
+		copied, err := utils.DeepCopy(v)
+		if err != nil {
+			return err
+		}
+		obj[k] = copied
+	}
+	return nil
+}
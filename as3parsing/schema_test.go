@@ -0,0 +1,110 @@
+package as3parsing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	f5_bigip "github.com/f5devcentral/f5-bigip-rest-go/bigip"
+)
+
+func TestAddDefaultsViaBigipTask(t *testing.T) {
+	defer func(interval, max, timeout time.Duration) {
+		as3TaskPollInterval, as3TaskPollMaxInterval, as3TaskTimeout = interval, max, timeout
+	}(as3TaskPollInterval, as3TaskPollMaxInterval, as3TaskTimeout)
+	as3TaskPollInterval, as3TaskPollMaxInterval, as3TaskTimeout = time.Millisecond, 4*time.Millisecond, 200*time.Millisecond
+
+	const (
+		inProgress = `{"results": [{"message": "in progress", "code": 0}]}`
+		succeeded  = `{"results": [{"message": "success", "code": 200}],
+			"declaration": {"class": "ADC", "T": {"class": "Tenant", "defaultRouteDomain": 0}}}`
+		failed = `{"results": [{"message": "declaration failed", "code": 422, "tenant": "T"}]}`
+	)
+	cases := []struct {
+		name string
+		// tasks are the responses of the task polls in order, the last one is repeated.
+		tasks   []string
+		timeout time.Duration
+		// err is the prefix of the error expected.
+		err string
+	}{
+		{
+			name:  "in progress then success",
+			tasks: []string{inProgress, inProgress, inProgress, succeeded},
+		},
+		{
+			name:  "failed",
+			tasks: []string{inProgress, failed},
+			err:   "as3 task abc failed: ",
+		},
+		{
+			name:    "cancelled",
+			tasks:   []string{inProgress},
+			timeout: 50 * time.Millisecond,
+			err:     "failed to wait for as3 task abc: context deadline exceeded",
+		},
+		{
+			name:  "timeout",
+			tasks: []string{inProgress},
+			err:   "failed to wait for as3 task abc: context deadline exceeded",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var polls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Basic token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch {
+				case r.Method == "POST" && r.URL.Path == "/mgmt/shared/appsvcs/declare":
+					// the task id is taken from selfLink.
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, `{"id": "xyz", "selfLink": "https://localhost/mgmt/shared/appsvcs/task/abc"}`)
+				case r.Method == "GET" && r.URL.Path == "/mgmt/shared/appsvcs/task/abc":
+					i := int(atomic.AddInt32(&polls, 1)) - 1
+					if i >= len(c.tasks) {
+						i = len(c.tasks) - 1
+					}
+					fmt.Fprint(w, c.tasks[i])
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			p, err := NewParser(WithBIGIP(&f5_bigip.BIGIP{URL: srv.URL, Authorization: "Basic token"}), WithAS3Service(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.TODO()
+			if c.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.timeout)
+				defer cancel()
+			}
+			decl, err := p.addDefaultsViaBigip(ctx, map[string]interface{}{"class": "ADC"})
+			if c.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+					t.Errorf("got error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, f := decl["T"]; !f {
+				t.Errorf("got declaration %v, want the one of the task", decl)
+			}
+			if n := atomic.LoadInt32(&polls); int(n) != len(c.tasks) {
+				t.Errorf("polled %d times, want %d", n, len(c.tasks))
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package as3parsing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TmshCommands renders "create ltm pool /T/A/web ..." lines, to run as a tmsh batch.
+	TmshCommands = "commands"
+	// TmshConfig renders "ltm pool /T/A/web { ... }" blocks, to load via 'tmsh load sys config merge file <file>'.
+	TmshConfig = "config"
+)
+
+// tmshSkipped are the REST body fields not rendered as tmsh properties.
+var tmshSkipped = map[string]bool{
+	"name":      true,
+	"partition": true,
+	"subPath":   true,
+	"fullPath":  true,
+	"kind":      true,
+}
+
+// RenderTmsh renders the REST objects in the format ParseAS3 returns as a tmsh script in dependency order.
+// The references are rendered as full paths, the built-in ones, i.e. "http", are taken as of /Common.
+//
+// tmsh cannot carry file contents, the files to upload before running the script,
+// i.e. the certificates, are listed as comments.
+func (p *Parser) RenderTmsh(restobjs map[string]interface{}, format string) (string, error) {
+	if format != TmshCommands && format != TmshConfig {
+		return "", fmt.Errorf("unknown tmsh format: %s", format)
+	}
+	result, err := NewResult(restobjs)
+	if err != nil {
+		return "", err
+	}
+	g := DependencyGraph(restobjs)
+	order, err := g.CreateOrder()
+	if err != nil {
+		return "", err
+	}
+	folders := map[[2]string][]ResourceID{}
+	for _, r := range g.Resources {
+		folders[[2]string{r.Partition, r.Folder}] = append(folders[[2]string{r.Partition, r.Folder}], r)
+	}
+
+	lines := []string{}
+	for _, pt := range result.Partitions {
+		if pt.Name != "Common" {
+			lines = append(lines, p.tmshObject(format, "auth partition", pt.Name, []string{}))
+		}
+		for _, f := range pt.Folders {
+			if f.Name != "" {
+				lines = append(lines, p.tmshObject(format, "sys folder", fmt.Sprintf("/%s/%s", pt.Name, f.Name), []string{}))
+			}
+		}
+	}
+
+	resources := map[ResourceID]*Resource{}
+	for _, res := range result.Resources("") {
+		resources[res.ID()] = res
+	}
+	for _, id := range order {
+		res := resources[id]
+		if res.Kind == "shared/file-transfer/uploads" {
+			lines = append(lines, fmt.Sprintf("# upload the content of %s to /var/config/rest/downloads/%s", res.FullPath(), res.Name))
+			continue
+		}
+		body := normalize(res.Body).(map[string]interface{})
+		for prefix, fields := range dependencyFields {
+			if strings.HasPrefix(res.Key(), prefix) {
+				absoluteRefs(body, fields, func(kinds []string, ref, def string) string {
+					if to, f := locateResource(folders, id, kinds, ref); f {
+						return (&Resource{Partition: to.Partition, Folder: to.Folder, Name: to.Key[strings.LastIndex(to.Key, "/")+1:]}).FullPath()
+					}
+					return def + ref
+				}, res.Partition)
+			}
+		}
+		lines = append(lines, p.tmshObject(format, strings.ReplaceAll(res.Kind, "/", " "), res.FullPath(), p.tmshProperties(format, res.Kind, body)))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// tmshObject renders one object with its properties.
+func (p *Parser) tmshObject(format, kind, name string, props []string) string {
+	if format == TmshCommands {
+		return strings.Join(append([]string{"create", kind, name}, props...), " ")
+	}
+	if len(props) == 0 {
+		return fmt.Sprintf("%s %s { }", kind, name)
+	}
+	return fmt.Sprintf("%s %s {\n    %s\n}", kind, name, strings.Join(props, "\n    "))
+}
+
+// tmshProperties renders the top level properties of the body, in the order of names.
+func (p *Parser) tmshProperties(format, kind string, body map[string]interface{}) []string {
+	props := []string{}
+	if kind == "ltm/rule" {
+		// the iRule definition is the body of the object itself.
+		if def, ok := body["apiAnonymous"].(string); ok {
+			if format == TmshCommands {
+				return []string{"{\n" + strings.TrimSpace(def) + "\n}"}
+			}
+			return []string{strings.TrimSpace(def)}
+		}
+	}
+	for _, k := range sortedKeys(body) {
+		if tmshSkipped[k] {
+			continue
+		}
+		v := body[k]
+		if k == "apiRawValues" {
+			props = append(props, tmshRawValues(v)...)
+			continue
+		}
+		name := p.tmshname(kind, k)
+		if b, ok := v.(bool); ok {
+			if b {
+				props = append(props, name)
+			}
+			continue
+		}
+		value := p.tmshValue(kind+"/"+name, v)
+		// in commands, the named items are added to the new object.
+		if format == TmshCommands && strings.HasPrefix(value, "{ ") && namedItems(toSlice(v)) != nil && len(toSlice(v)) > 0 {
+			value = "add " + value
+		}
+		props = append(props, name+" "+value)
+	}
+	return props
+}
+
+// tmshValue renders the value in tmsh syntax:
+//
+//	"string" -> string, or "quoted string" if it has spaces
+//	["a", "b"] -> { a b }
+//	[{"name": "n", "k": "v"}] -> { n { k v } }
+//	{"k": "v"} -> { k v }
+func (p *Parser) tmshValue(kind string, v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	if raw, ok := v.(tmshRaw); ok {
+		return string(raw)
+	}
+	switch reflect.TypeOf(v).Kind().String() {
+	case "string":
+		return tmshString(v.(string))
+	case "float64":
+		return strconv.FormatFloat(v.(float64), 'f', -1, 64)
+	case "bool":
+		return strconv.FormatBool(v.(bool))
+	case "slice":
+		items := v.([]interface{})
+		if len(items) == 0 {
+			return "none"
+		}
+		rendered := []string{}
+		for _, item := range items {
+			if mitem, ok := item.(map[string]interface{}); ok {
+				if n, ok := mitem["name"].(string); ok {
+					rendered = append(rendered, tmshString(n)+" "+p.tmshValue(kind, mitem))
+					continue
+				}
+			}
+			rendered = append(rendered, p.tmshValue(kind, item))
+		}
+		return "{ " + strings.Join(rendered, " ") + " }"
+	case "map":
+		props := p.tmshProperties(TmshConfig, kind, v.(map[string]interface{}))
+		if len(props) == 0 {
+			return "{ }"
+		}
+		return "{ " + strings.Join(props, " ") + " }"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// tmshname is the tmsh property name of the REST property, i.e. "load-balancing-mode" for "loadBalancingMode".
+// It's looked up in rest.properties.json, in the kind or its parent kinds, before turned to kebab case.
+func (p *Parser) tmshname(kind, restname string) string {
+	if restname == "tmName" {
+		// i.e. the hostname of the FQDN nodes.
+		return "name"
+	}
+	for k := kind; k != ""; k = parentKind(k) {
+		names := []string{}
+		for n, prop := range p.properties[k] {
+			if prop.RestName == restname && strings.Contains(n, "-") && camelCase(n) == restname {
+				names = append(names, n)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			return names[0]
+		}
+	}
+	return kebabCase(restname)
+}
+
+// tmshRawValues renders the external monitor variables {"userDefined K": "V"} as: user-defined K V
+func tmshRawValues(v interface{}) []string {
+	props := []string{}
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return props
+	}
+	for _, k := range sortedKeys(raw) {
+		if n := strings.TrimPrefix(k, "userDefined "); n != k {
+			props = append(props, fmt.Sprintf("user-defined %s %s", n, tmshString(fmt.Sprintf("%v", raw[k]))))
+		}
+	}
+	return props
+}
+
+// tmshString quotes the string if it has spaces or tmsh special characters,
+// the line breaks are escaped to keep the object on one line, i.e. the monitor's send "GET /\r\n".
+func tmshString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.ContainsAny(s, " \t\r\n\"{}#;") {
+		return `"` + tmshEscaper.Replace(s) + `"`
+	}
+	return s
+}
+
+var tmshEscaper = strings.NewReplacer(`"`, `\"`, "\r", `\r`, "\n", `\n`, "\t", `\t`)
+
+// absoluteRefs rewrites the relative references in the fields of obj, at any depth,
+// to the full paths returned by resolve. def is the folder assumed if the reference is not found.
+func absoluteRefs(obj interface{}, fields map[string][]string, resolve func(kinds []string, ref, def string) string, partition string) {
+	abs := func(kinds []string, ref, def string) string {
+		switch {
+		case ref == "":
+			return "none"
+		case ref == "default" || ref == "none" || strings.HasPrefix(ref, "/"):
+			return ref
+		}
+		return resolve(kinds, ref, def)
+	}
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range o {
+			kinds, f := fields[k]
+			if !f {
+				absoluteRefs(v, fields, resolve, partition)
+				continue
+			}
+			switch k {
+			case "destination":
+				if s, ok := v.(string); ok {
+					addr := stripPort(s)
+					o[k] = abs(kinds, addr, "/"+partition+"/") + s[len(addr):]
+				}
+			case "members":
+				for _, item := range toSlice(v) {
+					if mitem, ok := item.(map[string]interface{}); ok {
+						if s, ok := mitem["name"].(string); ok {
+							addr := stripPort(s)
+							mitem["name"] = abs(kinds, addr, "/"+partition+"/") + s[len(addr):]
+						}
+					}
+				}
+				absoluteRefs(v, fields, resolve, partition)
+			case "monitor":
+				if s, ok := v.(string); ok {
+					tokens := strings.Fields(s)
+					refs := referredNames(k, s)
+					for i, t := range tokens {
+						for _, ref := range refs {
+							if t == ref {
+								tokens[i] = abs(kinds, t, "/Common/")
+							}
+						}
+					}
+					o[k] = tmshMonitor(tokens)
+				}
+			case "sourcePath":
+			default:
+				switch tv := v.(type) {
+				case string:
+					o[k] = abs(kinds, tv, "/Common/")
+				case []interface{}:
+					for i, item := range tv {
+						switch ti := item.(type) {
+						case string:
+							tv[i] = abs(kinds, ti, "/Common/")
+						case map[string]interface{}:
+							if n, ok := ti["name"].(string); ok {
+								ti["name"] = abs(kinds, n, "/Common/")
+							}
+						}
+					}
+				default:
+					absoluteRefs(v, fields, resolve, partition)
+				}
+			}
+		}
+	case []interface{}:
+		for _, v := range o {
+			absoluteRefs(v, fields, resolve, partition)
+		}
+	}
+}
+
+// tmshRaw is rendered as it is, not quoted.
+type tmshRaw string
+
+// tmshMonitor renders the pool monitor rule, i.e. "min 1 of { /Common/http /T/A/mon }", "none" for an empty one.
+func tmshMonitor(tokens []string) tmshRaw {
+	if len(tokens) == 0 {
+		return tmshRaw("none")
+	}
+	if len(tokens) > 3 && tokens[0] == "min" && tokens[2] == "of" {
+		return tmshRaw(strings.Join(tokens[:3], " ") + " { " + strings.Join(tokens[3:], " ") + " }")
+	}
+	return tmshRaw(strings.Join(tokens, " "))
+}
+
+func parentKind(kind string) string {
+	if i := strings.LastIndex(kind, "/"); i > 0 {
+		return kind[:i]
+	}
+	return ""
+}
+
+func toSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func kebabCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
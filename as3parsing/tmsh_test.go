@@ -0,0 +1,212 @@
+package as3parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+// tmshObjects are the REST objects of a virtual with its pool, monitors, iRule and client-ssl profile.
+func tmshObjects() map[string]interface{} {
+	return map[string]interface{}{
+		"T": map[string]interface{}{
+			"": map[string]interface{}{
+				"ltm/node/10.1.0.1":            map[string]interface{}{"name": "10.1.0.1", "address": "10.1.0.1"},
+				"ltm/virtual-address/10.0.0.1": map[string]interface{}{"name": "10.0.0.1", "address": "10.0.0.1", "icmpEcho": "enabled"},
+			},
+			"A": map[string]interface{}{
+				"ltm/virtual/vs": map[string]interface{}{
+					"name":                     "vs",
+					"destination":              "/T/10.0.0.1:80",
+					"pool":                     "web",
+					"ipProtocol":               "tcp",
+					"profiles":                 []interface{}{map[string]interface{}{"name": "http", "context": "all"}},
+					"rules":                    []interface{}{"r1"},
+					"description":              "the web service",
+					"enabled":                  true,
+					"disabled":                 false,
+					"sourceAddressTranslation": map[string]interface{}{"type": "automap"},
+				},
+				"ltm/pool/web": map[string]interface{}{
+					"name":              "web",
+					"loadBalancingMode": "round-robin",
+					"members":           []interface{}{map[string]interface{}{"name": "/T/10.1.0.1:80", "ratio": float64(1)}},
+					"monitor":           "min 1 of m http",
+				},
+				"ltm/pool/empty":     map[string]interface{}{"name": "empty", "monitor": "", "members": []interface{}{}},
+				"ltm/monitor/http/m": map[string]interface{}{"name": "m", "send": "GET /\r\n", "interval": float64(5)},
+				"ltm/monitor/external/ext": map[string]interface{}{
+					"name": "ext", "run": "/Common/arg_example",
+					"apiRawValues": map[string]interface{}{"userDefined A": "x y", "userDefined B": "1"},
+				},
+				"ltm/rule/r1": map[string]interface{}{"name": "r1", "apiAnonymous": "when HTTP_REQUEST {\n  log local0. \"hi\"\n}\n"},
+				"ltm/profile/client-ssl/tls": map[string]interface{}{
+					"name":         "tls",
+					"certKeyChain": []interface{}{map[string]interface{}{"name": "default", "cert": "c.crt", "key": "c.key"}},
+				},
+				"sys/file/ssl-cert/c.crt":                   map[string]interface{}{"name": "c.crt", "sourcePath": "file:/var/config/rest/downloads/_T__A__c.crt"},
+				"sys/file/ssl-key/c.key":                    map[string]interface{}{"name": "c.key", "sourcePath": "file:/var/config/rest/downloads/_T__A__c.key"},
+				"shared/file-transfer/uploads/_T__A__c.crt": map[string]interface{}{"name": "_T__A__c.crt", "content": "crt"},
+				"shared/file-transfer/uploads/_T__A__c.key": map[string]interface{}{"name": "_T__A__c.key", "content": "key"},
+			},
+		},
+	}
+}
+
+func TestRenderTmsh(t *testing.T) {
+	cases := []struct {
+		name   string
+		objs   map[string]interface{}
+		format string
+		want   []string
+		err    string
+	}{
+		{
+			name:   "commands",
+			objs:   tmshObjects(),
+			format: TmshCommands,
+			want: []string{
+				`create auth partition T`,
+				`create sys folder /T/A`,
+				`create ltm node /T/10.1.0.1 address 10.1.0.1`,
+				`create ltm virtual-address /T/10.0.0.1 address 10.0.0.1 icmp-echo enabled`,
+				`create ltm monitor external /T/A/ext user-defined A "x y" user-defined B 1 run /Common/arg_example`,
+				`create ltm monitor http /T/A/m interval 5 send "GET /\r\n"`,
+				`create ltm pool /T/A/empty members none monitor none`,
+				`create ltm pool /T/A/web load-balancing-mode round-robin members add { /T/10.1.0.1:80 { ratio 1 } } monitor min 1 of { /T/A/m /Common/http }`,
+				`# upload the content of /T/A/_T__A__c.crt to /var/config/rest/downloads/_T__A__c.crt`,
+				`create sys file ssl-cert /T/A/c.crt source-path file:/var/config/rest/downloads/_T__A__c.crt`,
+				`# upload the content of /T/A/_T__A__c.key to /var/config/rest/downloads/_T__A__c.key`,
+				`create sys file ssl-key /T/A/c.key source-path file:/var/config/rest/downloads/_T__A__c.key`,
+				`create ltm profile client-ssl /T/A/tls cert-key-chain add { default { cert /T/A/c.crt key /T/A/c.key } }`,
+				`create ltm rule /T/A/r1 {`,
+				`when HTTP_REQUEST {`,
+				`  log local0. "hi"`,
+				`}`,
+				`}`,
+				`create ltm virtual /T/A/vs description "the web service" destination /T/10.0.0.1:80 enabled ip-protocol tcp pool /T/A/web profiles add { /Common/http { context all } } rules { /T/A/r1 } source-address-translation { type automap }`,
+			},
+		},
+		{
+			name:   "config",
+			objs:   tmshObjects(),
+			format: TmshConfig,
+			want: []string{
+				`auth partition T { }`,
+				`sys folder /T/A { }`,
+				`ltm node /T/10.1.0.1 {`,
+				`    address 10.1.0.1`,
+				`}`,
+				`ltm virtual-address /T/10.0.0.1 {`,
+				`    address 10.0.0.1`,
+				`    icmp-echo enabled`,
+				`}`,
+				`ltm monitor external /T/A/ext {`,
+				`    user-defined A "x y"`,
+				`    user-defined B 1`,
+				`    run /Common/arg_example`,
+				`}`,
+				`ltm monitor http /T/A/m {`,
+				`    interval 5`,
+				`    send "GET /\r\n"`,
+				`}`,
+				`ltm pool /T/A/empty {`,
+				`    members none`,
+				`    monitor none`,
+				`}`,
+				`ltm pool /T/A/web {`,
+				`    load-balancing-mode round-robin`,
+				`    members { /T/10.1.0.1:80 { ratio 1 } }`,
+				`    monitor min 1 of { /T/A/m /Common/http }`,
+				`}`,
+				`# upload the content of /T/A/_T__A__c.crt to /var/config/rest/downloads/_T__A__c.crt`,
+				`sys file ssl-cert /T/A/c.crt {`,
+				`    source-path file:/var/config/rest/downloads/_T__A__c.crt`,
+				`}`,
+				`# upload the content of /T/A/_T__A__c.key to /var/config/rest/downloads/_T__A__c.key`,
+				`sys file ssl-key /T/A/c.key {`,
+				`    source-path file:/var/config/rest/downloads/_T__A__c.key`,
+				`}`,
+				`ltm profile client-ssl /T/A/tls {`,
+				`    cert-key-chain { default { cert /T/A/c.crt key /T/A/c.key } }`,
+				`}`,
+				`ltm rule /T/A/r1 {`,
+				`    when HTTP_REQUEST {`,
+				`  log local0. "hi"`,
+				`}`,
+				`}`,
+				`ltm virtual /T/A/vs {`,
+				`    description "the web service"`,
+				`    destination /T/10.0.0.1:80`,
+				`    enabled`,
+				`    ip-protocol tcp`,
+				`    pool /T/A/web`,
+				`    profiles { /Common/http { context all } }`,
+				`    rules { /T/A/r1 }`,
+				`    source-address-translation { type automap }`,
+				`}`,
+			},
+		},
+		{
+			name: "common",
+			objs: map[string]interface{}{
+				"Common": map[string]interface{}{
+					"Shared": map[string]interface{}{
+						"ltm/pool/p": map[string]interface{}{"name": "p", "monitor": "http"},
+					},
+				},
+			},
+			format: TmshCommands,
+			want: []string{
+				`create sys folder /Common/Shared`,
+				`create ltm pool /Common/Shared/p monitor /Common/http`,
+			},
+		},
+		{
+			name:   "unknown format",
+			objs:   tmshObjects(),
+			format: "json",
+			err:    "unknown tmsh format: json",
+		},
+		{
+			name: "circular dependency",
+			objs: map[string]interface{}{
+				"T": map[string]interface{}{
+					"A": map[string]interface{}{
+						"ltm/virtual/vs": map[string]interface{}{
+							"policies": []interface{}{map[string]interface{}{"name": "/T/A/pol"}},
+						},
+						"ltm/policy/pol": map[string]interface{}{
+							"rules": []interface{}{map[string]interface{}{
+								"actions": []interface{}{map[string]interface{}{"forward": true, "virtual": "/T/A/vs"}},
+							}},
+						},
+					},
+				},
+			},
+			format: TmshConfig,
+			err:    "circular dependency: /T/A/ltm/policy/pol -> /T/A/ltm/virtual/vs -> /T/A/ltm/policy/pol",
+		},
+	}
+
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := p.RenderTmsh(c.objs, c.format)
+			if c.err != "" {
+				if err == nil || err.Error() != c.err {
+					t.Errorf("got error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(c.want, "\n") + "\n"; got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package as3parsing
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RestOperation is one iControl REST request.
+type RestOperation struct {
+	Method  string            `json:"method"`
+	URI     string            `json:"uri"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the json body, or the raw content for file uploads.
+	Body interface{} `json:"body,omitempty"`
+}
+
+// RestTransaction is the ready-to-send iControl REST requests:
+//
+//	Prepare    -> to send before the transaction, i.e. creating partitions and folders and uploading files
+//	Operations -> to send within the transaction, with the header X-F5-REST-Coordination-Id
+//	Cleanup    -> to send after the transaction is committed, i.e. deleting folders and partitions
+type RestTransaction struct {
+	Prepare    []RestOperation `json:"prepare"`
+	Operations []RestOperation `json:"operations"`
+	Cleanup    []RestOperation `json:"cleanup"`
+}
+
+// NewRestTransaction generates the requests from the existing REST objects, nil for none, to restobjs,
+// both in the format ParseAS3 returns. The resources are POSTed if they don't exist, PATCHed if changed,
+// and DELETEd if not in restobjs any more, in the dependency order. The sys/file resources are PATCHed
+// as well when the files they are created from are uploaded again, for BIG-IP to reload the content.
+// Folders are created and deleted out of the transaction, along with the partitions.
+func NewRestTransaction(existing, restobjs map[string]interface{}) (*RestTransaction, error) {
+	if existing == nil {
+		existing = map[string]interface{}{}
+	}
+	plan := DiffRest(existing, restobjs)
+	plan, err := append(plan, fileReloads(plan, restobjs)...).Ordered(existing, restobjs)
+	if err != nil {
+		return nil, err
+	}
+	t := &RestTransaction{
+		Prepare:    []RestOperation{},
+		Operations: []RestOperation{},
+		Cleanup:    []RestOperation{},
+	}
+
+	for _, pn := range sortedKeys(restobjs) {
+		if _, f := existing[pn]; !f && pn != "Common" {
+			t.Prepare = append(t.Prepare, RestOperation{
+				Method: "POST",
+				URI:    "/mgmt/tm/auth/partition",
+				Body:   map[string]interface{}{"name": pn},
+			})
+		}
+		pobj, _ := restobjs[pn].(map[string]interface{})
+		for _, fn := range sortedKeys(pobj) {
+			if fn != "" && !hasFolder(existing, pn, fn) {
+				t.Prepare = append(t.Prepare, RestOperation{
+					Method: "POST",
+					URI:    "/mgmt/tm/sys/folder",
+					Body:   map[string]interface{}{"name": fn, "partition": pn},
+				})
+			}
+		}
+	}
+
+	for _, op := range plan {
+		kind, name := op.Key[:strings.LastIndex(op.Key, "/")], op.Key[strings.LastIndex(op.Key, "/")+1:]
+		if kind == "shared/file-transfer/uploads" {
+			// the uploaded files are removed by BIG-IP once used, so only uploads are sent.
+			if op.Op != OpDelete {
+				t.Prepare = append(t.Prepare, uploadOperation(name, op.Body))
+			}
+			continue
+		}
+		switch op.Op {
+		case OpCreate:
+			body := map[string]interface{}{}
+			for k, v := range op.Body {
+				body[k] = v
+			}
+			body["name"], body["partition"] = name, op.Partition
+			if op.Folder != "" {
+				body["subPath"] = op.Folder
+			}
+			t.Operations = append(t.Operations, RestOperation{Method: "POST", URI: "/mgmt/tm/" + kind, Body: body})
+		case OpUpdate:
+			t.Operations = append(t.Operations, RestOperation{
+				Method: "PATCH",
+				URI:    "/mgmt/tm/" + kind + "/" + refname(op.Partition, op.Folder, name),
+				Body:   op.Body,
+			})
+		case OpDelete:
+			t.Operations = append(t.Operations, RestOperation{
+				Method: "DELETE",
+				URI:    "/mgmt/tm/" + kind + "/" + refname(op.Partition, op.Folder, name),
+			})
+		}
+	}
+
+	for _, pn := range sortedKeys(existing) {
+		pobj, ok := existing[pn].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, fn := range sortedKeys(pobj) {
+			if fn != "" && !hasFolder(restobjs, pn, fn) {
+				t.Cleanup = append(t.Cleanup, RestOperation{
+					Method: "DELETE",
+					URI:    "/mgmt/tm/sys/folder/" + refname(pn, fn, ""),
+				})
+			}
+		}
+		if _, f := restobjs[pn]; !f && pn != "Common" {
+			t.Cleanup = append(t.Cleanup, RestOperation{
+				Method: "DELETE",
+				URI:    "/mgmt/tm/auth/partition/" + pn,
+			})
+		}
+	}
+	return t, nil
+}
+
+// fileReloads returns the updates of the sys/file resources not changed in plan, whose files are uploaded again.
+func fileReloads(plan Plan, restobjs map[string]interface{}) Plan {
+	uploads, planned := map[string]bool{}, map[[3]string]bool{}
+	for _, op := range plan {
+		planned[[3]string{op.Partition, op.Folder, op.Key}] = true
+		if op.Op == OpUpdate && strings.HasPrefix(op.Key, "shared/file-transfer/uploads/") {
+			uploads[strings.TrimPrefix(op.Key, "shared/file-transfer/uploads/")] = true
+		}
+	}
+	reloads := Plan{}
+	if len(uploads) == 0 {
+		return reloads
+	}
+	for k, body := range flattenRest(restobjs) {
+		if !strings.HasPrefix(k[2], "sys/file/") || planned[k] {
+			continue
+		}
+		sourcePath, _ := body["sourcePath"].(string)
+		if uploads[sourcePath[strings.LastIndex(sourcePath, "/")+1:]] {
+			reloads = append(reloads, Operation{Op: OpUpdate, Partition: k[0], Folder: k[1], Key: k[2], Body: body})
+		}
+	}
+	return reloads
+}
+
+func uploadOperation(name string, body map[string]interface{}) RestOperation {
+	content := fmt.Sprintf("%v", body["content"])
+	size := len(content)
+	headers := map[string]string{
+		"Content-Type":   "application/octet-stream",
+		"Content-Length": fmt.Sprintf("%d", size),
+	}
+	// an empty file has no byte range.
+	if size > 0 {
+		headers["Content-Range"] = fmt.Sprintf("0-%d/%d", size-1, size)
+	}
+	return RestOperation{
+		Method:  "POST",
+		URI:     "/mgmt/shared/file-transfer/uploads/" + name,
+		Headers: headers,
+		Body:    content,
+	}
+}
+
+func hasFolder(restobjs map[string]interface{}, partition, folder string) bool {
+	pobj, ok := restobjs[partition].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, f := pobj[folder]
+	return f
+}
+
+// refname is the name in the resource uri, i.e. ~T~A~web, the same as f5-bigip-rest-go does.
+func refname(partition, folder, name string) string {
+	l := []string{}
+	for _, x := range []string{partition, folder, name} {
+		if x != "" {
+			l = append(l, x)
+		}
+	}
+	rn := strings.Join(l, "~")
+	if rn != "" {
+		rn = "~" + rn
+	}
+	return strings.ReplaceAll(url.QueryEscape(rn), "%2F", "/")
+}
@@ -0,0 +1,110 @@
+package as3parsing
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewRestTransaction(t *testing.T) {
+	cert := func(content string) map[string]interface{} {
+		return map[string]interface{}{
+			"T": map[string]interface{}{
+				"A": map[string]interface{}{
+					"sys/file/ssl-cert/c.crt":                   map[string]interface{}{"name": "c.crt", "sourcePath": "file:/var/config/rest/downloads/_T__A__c.crt"},
+					"shared/file-transfer/uploads/_T__A__c.crt": map[string]interface{}{"content": content},
+				},
+			},
+		}
+	}
+	pool := map[string]interface{}{
+		"T": map[string]interface{}{
+			"A": map[string]interface{}{
+				"ltm/pool/web": map[string]interface{}{"name": "web"},
+			},
+		},
+	}
+
+	cases := []struct {
+		name                          string
+		existing, restobjs            map[string]interface{}
+		prepare, operations, cleanups []string
+	}{
+		{
+			name:     "changed certificate",
+			existing: cert("old"),
+			restobjs: cert("new"),
+			prepare: []string{
+				"POST /mgmt/shared/file-transfer/uploads/_T__A__c.crt Content-Length:3 Content-Range:0-2/3",
+			},
+			operations: []string{"PATCH /mgmt/tm/sys/file/ssl-cert/~T~A~c.crt"},
+		},
+		{
+			name:     "empty upload",
+			existing: cert("old"),
+			restobjs: cert(""),
+			prepare: []string{
+				"POST /mgmt/shared/file-transfer/uploads/_T__A__c.crt Content-Length:0",
+			},
+			operations: []string{"PATCH /mgmt/tm/sys/file/ssl-cert/~T~A~c.crt"},
+		},
+		{
+			name:     "unchanged certificate",
+			existing: cert("crt"),
+			restobjs: cert("crt"),
+		},
+		{
+			name:     "new partition and folder",
+			restobjs: pool,
+			prepare: []string{
+				"POST /mgmt/tm/auth/partition",
+				"POST /mgmt/tm/sys/folder",
+			},
+			operations: []string{"POST /mgmt/tm/ltm/pool"},
+		},
+		{
+			name:       "deleted partition and folder",
+			existing:   pool,
+			restobjs:   map[string]interface{}{},
+			operations: []string{"DELETE /mgmt/tm/ltm/pool/~T~A~web"},
+			cleanups: []string{
+				"DELETE /mgmt/tm/sys/folder/~T~A",
+				"DELETE /mgmt/tm/auth/partition/T",
+			},
+		},
+	}
+
+	format := func(ops []RestOperation) string {
+		ss := []string{}
+		for _, op := range ops {
+			s := op.Method + " " + op.URI
+			for _, h := range []string{"Content-Length", "Content-Range"} {
+				if v, f := op.Headers[h]; f {
+					s += fmt.Sprintf(" %s:%s", h, v)
+				}
+			}
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, "\n")
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tx, err := NewRestTransaction(c.existing, c.restobjs)
+			if err != nil {
+				t.Fatalf("NewRestTransaction: %s", err)
+			}
+			for _, s := range []struct {
+				name      string
+				got, want string
+			}{
+				{"Prepare", format(tx.Prepare), strings.Join(c.prepare, "\n")},
+				{"Operations", format(tx.Operations), strings.Join(c.operations, "\n")},
+				{"Cleanup", format(tx.Cleanup), strings.Join(c.cleanups, "\n")},
+			} {
+				if s.got != s.want {
+					t.Errorf("%s got:\n%s\nwant:\n%s", s.name, s.got, s.want)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package as3parsing
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
+
+	f5_bigip "github.com/f5devcentral/f5-bigip-rest-go/bigip"
+)
 
 type Property struct {
 	RestName        string                 `json:"restname"`
@@ -16,9 +22,51 @@ type Property struct {
 
 type Properties map[string]Property
 
+// Parser converts as3 declarations to iControl REST objects.
+// Multiple parsers can work at the same time, i.e. for multiple BIG-IPs of different versions.
+type Parser struct {
+	bigip        *f5_bigip.BIGIP
+	as3Service   string
+	defaultsMode string
+	properties   map[string]Properties
+	defaults     SchemaDefaults
+	version      string
+	readiness    string
+	retryPolicy  RetryPolicy
+	concurrency  int
+	discoverers  map[string]MemberDiscoverer
+	ready        bool
+	readyMutex   sync.Mutex
+}
+
+// RetryPolicy tells how many times to try, and how long to wait between tries.
+type RetryPolicy struct {
+	Times    int
+	Interval time.Duration
+}
+
+type ParserOption func(p *Parser)
+
 type ParseContext struct {
 	context.Context
+	*Parser
 }
+
+type ReverseContext struct {
+	context.Context
+	*Parser
+	restobjs map[string]interface{}
+}
+
 type ConvertContext struct {
 	context.Context
+	*Parser
+	// parsed holds the whole parsed tree, for looking up the objects referred by full paths.
+	parsed map[string]interface{}
+	// routeDomains holds the defaultRouteDomain of the tenants.
+	routeDomains map[string]int
 }
+
+// SchemaDefaults holds the as3 default values per class, i.e. "Pool",
+// per array item of a class, i.e. "Pool/members", and per monitor type, i.e. "Monitor/http".
+type SchemaDefaults map[string]map[string]interface{}
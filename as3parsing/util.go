@@ -2,12 +2,11 @@ package as3parsing
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net/http"
+	"net"
 	"reflect"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/f5devcentral/f5-bigip-rest-go/utils"
 )
@@ -29,6 +28,72 @@ func refers(obj interface{}) string {
 	return ""
 }
 
+// checkRef checks obj, the value of the property name, refers to an object, by its name, {"use": name} or {"bigip": path}.
+func checkRef(name string, obj interface{}) error {
+	if _, ok := obj.(string); ok {
+		return nil
+	}
+	if mobj, ok := obj.(map[string]interface{}); ok {
+		for _, k := range []string{"use", "bigip"} {
+			if ref, f := mobj[k]; f {
+				if _, ok := ref.(string); ok {
+					return nil
+				}
+			}
+		}
+	}
+	return newDiagError(CodeInvalidValue, "%s should be a name, {\"use\": name} or {\"bigip\": path}: %v", name, obj)
+}
+
+// checkRefs checks obj is an array of references, or a single reference if single is true.
+func checkRefs(name string, obj interface{}, single bool) error {
+	items, ok := obj.([]interface{})
+	if !ok {
+		if single {
+			return checkRef(name, obj)
+		}
+		return newDiagError(CodeInvalidValue, "%s should be an array: %v", name, obj)
+	}
+	for i, item := range items {
+		if err := checkRef(fmt.Sprintf("%s item %d", name, i), item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serviceRefs are the Service_* properties referring to an array of objects,
+// true if a single reference is also accepted.
+var serviceRefs = map[string]bool{
+	"serverTLS":          true,
+	"clientTLS":          true,
+	"policyEndpoint":     true,
+	"iRules":             false,
+	"persistenceMethods": false,
+}
+
+// checkServiceProperty checks the type of the Service_* property k.
+func checkServiceProperty(k string, v interface{}) error {
+	switch k {
+	case "pool", "snat", "profileTCP", "profileHTTP", "profileMultiplex", "profileFTP", "profileUDP", "profileSCTP", "profileL4":
+		return checkRef(k, v)
+	}
+	if single, f := serviceRefs[k]; f {
+		return checkRefs(k, v, single)
+	}
+	switch k {
+	case "shareAddresses", "redirect80":
+		if _, ok := v.(bool); !ok {
+			return newDiagError(CodeInvalidValue, "%s should be a boolean: %v", k, v)
+		}
+	case "mirroring":
+		if _, ok := v.(string); !ok {
+			return newDiagError(CodeInvalidValue, "%s should be a string: %v", k, v)
+		}
+	}
+	return nil
+}
+
 func tlsRefers(name, pf string, obj interface{}) string {
 	if t := reflect.TypeOf(obj).Kind().String(); t == "string" {
 		return fmt.Sprintf("%s/%s", pf, name)
@@ -37,14 +102,17 @@ func tlsRefers(name, pf string, obj interface{}) string {
 		if value, f := mobj["bigip"]; f {
 			return value.(string)
 		} else if value, f := mobj["use"]; f {
+			if strings.HasPrefix(value.(string), "/") {
+				return value.(string)
+			}
 			return fmt.Sprintf("%s/%s", pf, value)
 		}
 	}
 	return ""
 }
 
-func restname(kind, as3name string) string {
-	if k, f := properties[kind]; f {
+func (p *Parser) restname(kind, as3name string) string {
+	if k, f := p.properties[kind]; f {
 		if n, f := k[as3name]; f {
 			return n.RestName
 		}
@@ -77,8 +145,8 @@ func renamePersist(t string) string {
 	return rt
 }
 
-func referToAddr(obj map[string]interface{}, name string) string {
-	if sa, f := obj["ltm/virtual-address/"+name]; f {
+func (cc *ConvertContext) referToAddr(parent, ref string, objsrc map[string]interface{}) string {
+	if sa, f := cc.lookup("ltm/virtual-address", parent, ref, objsrc); f {
 		if va, f := sa.(map[string]interface{})["virtualAddress"]; f {
 			return va.(string)
 		}
@@ -103,49 +171,185 @@ func indexedName(i int, name string) string {
 	}
 }
 
-func waitForAs3Service() {
-	slog := utils.LogFromContext(context.TODO())
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-		Timeout: 10 * time.Second,
+func newParseContext(ctx context.Context, p *Parser) *ParseContext {
+	return &ParseContext{ctx, p}
+}
+
+func newReverseContext(ctx context.Context, p *Parser, restobjs map[string]interface{}) *ReverseContext {
+	return &ReverseContext{ctx, p, restobjs}
+}
+
+func newConvertContext(ctx context.Context, p *Parser) *ConvertContext {
+	return &ConvertContext{ctx, p, nil, map[string]int{}}
+}
+
+// tenantRouteDomains collects the defaultRouteDomain of the tenants in the declaration.
+func tenantRouteDomains(declaration map[string]interface{}) (map[string]int, error) {
+	rds := map[string]int{}
+	ds := Diagnostics{}
+	for k, v := range declaration {
+		tenant, ok := v.(map[string]interface{})
+		if !ok || tenant["class"] != "Tenant" {
+			continue
+		}
+		rd, f := tenant["defaultRouteDomain"]
+		if !f {
+			continue
+		}
+		n, ok := rd.(float64)
+		if !ok || n < 0 || n > 65534 || n != float64(int(n)) {
+			ds.add(k+"/defaultRouteDomain", newDiagError(CodeInvalidValue, "defaultRouteDomain should be an integer of 0-65534: %v", rd))
+			continue
+		}
+		rds[k] = int(n)
 	}
-	as3ep := fmt.Sprintf("%s/any", as3Service)
-	if strings.HasPrefix(as3Service, bigip.URL) {
-		as3ep = fmt.Sprintf("%s/mgmt/shared/appsvcs/info", bigip.URL)
+	return rds, ds.err()
+}
+
+// routeDomain is the defaultRouteDomain of the tenant of parent, i.e. /T/A, or the tenant itself, i.e. T.
+func (cc *ConvertContext) routeDomain(parent string) int {
+	return cc.routeDomains[strings.Split(strings.TrimPrefix(parent, "/"), "/")[0]]
+}
+
+// tenantAddress validates the address, i.e. "10.1.1.1" or "2001::1%2", and applies the defaultRouteDomain
+// of the tenant of parent if it has no route domain. The prefix length is not allowed.
+func (cc *ConvertContext) tenantAddress(parent, addr string) (string, error) {
+	a, mask, err := splitAddress(addr)
+	if err != nil {
+		return "", err
 	}
-	tryGet := func() error {
-		if status, response, err := utils.HttpRequest(
-			client, as3ep, "GET", "", map[string]string{
-				"Authorization": bigip.Authorization,
-			}); err != nil {
-			return err
-		} else if status == 200 {
-			return nil
-		} else {
-			return fmt.Errorf("as3 parser service response with code %d, response: %s", status, response)
-		}
+	if mask != "" {
+		return "", fmt.Errorf("prefix length is not allowed in address %s", addr)
+	}
+	return rdAddress(a, cc.routeDomain(parent)), nil
+}
+
+// rdAddress appends the route domain to the address, i.e. 10.0.0.1%2,
+// the default route domain 0 is omitted, an address with route domain already is kept as it is.
+func rdAddress(addr string, rd int) string {
+	if rd <= 0 || strings.Contains(addr, "%") {
+		return addr
+	}
+	return fmt.Sprintf("%s%%%d", addr, rd)
+}
+
+// splitAddress splits the as3 address, i.e. "10.1.0.0%2/16", to the address with the route domain, "10.1.0.0%2",
+// and the mask, "255.255.0.0", or "" if no prefix length is given.
+func splitAddress(s string) (string, string, error) {
+	addr, bits := s, ""
+	if i := strings.Index(s, "/"); i >= 0 {
+		addr, bits = s[:i], s[i+1:]
 	}
-	interval := 10
-	times := 60
-	for i := 0; i < times; i++ {
-		if err := tryGet(); err != nil {
-			slog.Warnf("%s, timeout %d", err.Error(), times-i)
-			time.Sleep(time.Duration(interval) * time.Second)
-		} else {
-			return
+	ipstr := addr
+	if i := strings.Index(addr, "%"); i >= 0 {
+		ipstr = addr[:i]
+		if rd, err := strconv.Atoi(addr[i+1:]); err != nil || rd < 0 || rd > 65534 {
+			return "", "", fmt.Errorf("invalid route domain in address %s", s)
 		}
 	}
-	panic(fmt.Errorf("as3 parser service is not available, abort"))
+	ip := net.ParseIP(ipstr)
+	if ip == nil {
+		return "", "", fmt.Errorf("invalid address %s", s)
+	}
+	if bits == "" {
+		return addr, "", nil
+	}
+	n, err := strconv.Atoi(bits)
+	size := 128
+	if ip.To4() != nil {
+		size = 32
+	}
+	if err != nil || n < 0 || n > size {
+		return "", "", fmt.Errorf("invalid prefix length in address %s", s)
+	}
+	return addr, net.IP(net.CIDRMask(n, size)).String(), nil
+}
+
+// addrRouteDomain is the route domain of the address, i.e. 2 for "10.1.0.0%2/16", 0 if not given.
+func addrRouteDomain(addr string) int {
+	i := strings.Index(addr, "%")
+	if i < 0 {
+		return 0
+	}
+	rd, _ := strconv.Atoi(strings.Split(addr[i+1:], "/")[0])
+	return rd
 }
 
-func newParseContext(ctx context.Context) *ParseContext {
-	return &ParseContext{ctx}
+// maskBits is the prefix length of the mask, i.e. 16 for "255.255.0.0", -1 if it's not a valid mask.
+func maskBits(mask string) int {
+	ip := net.ParseIP(mask)
+	if ip == nil {
+		return -1
+	}
+	if ip4 := ip.To4(); ip4 != nil && !strings.Contains(mask, ":") {
+		ip = ip4
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return -1
+	}
+	return ones
+}
+
+// addrPort joins the address and the port as BIG-IP does, i.e. 10.0.0.1%2:80, 2001::1.80
+func addrPort(addr string, port interface{}) string {
+	ip := strings.Split(addr, "%")[0]
+	if utils.IsIpv6(ip) {
+		return fmt.Sprintf("%s.%v", addr, port)
+	} else {
+		return fmt.Sprintf("%s:%v", addr, port)
+	}
+}
+
+// recordString formats the key or value of a data group record, numbers in plain decimal, i.e. 1000000 not 1e+06.
+func recordString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
 }
 
-func newConvertContext(ctx context.Context) *ConvertContext {
-	return &ConvertContext{ctx}
+// dataGroupFileContent renders the records as the content of an external data group file,
+// separated by sep, i.e.
+//
+//	"key" := "value",
+//	1 := "value",
+//	network 10.0.0.0/8 := "value",
+//	host 10.1.1.1 := "value",
+func dataGroupFileContent(keyType, sep string, records interface{}) (string, error) {
+	items, ok := records.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("data group records should be an array")
+	}
+	lines := []string{}
+	for _, item := range items {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("data group record should be an object: %v", item)
+		}
+		key, f := record["key"]
+		if !f {
+			return "", fmt.Errorf("data group record requires key: %v", item)
+		}
+		var line string
+		switch keyType {
+		case "string":
+			line = fmt.Sprintf("%q", key)
+		case "integer":
+			line = recordString(key)
+		case "ip":
+			if strings.Contains(fmt.Sprintf("%v", key), "/") {
+				line = fmt.Sprintf("network %v", key)
+			} else {
+				line = fmt.Sprintf("host %v", key)
+			}
+		default:
+			return "", fmt.Errorf("unknown data group keyDataType: %s", keyType)
+		}
+		if value, f := record["value"]; f {
+			line = fmt.Sprintf("%s %s %q", line, sep, recordString(value))
+		}
+		lines = append(lines, line+",")
+	}
+	return strings.Join(lines, "\n") + "\n", nil
 }
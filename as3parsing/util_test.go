@@ -0,0 +1,224 @@
+package as3parsing
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataGroupFileContent(t *testing.T) {
+	cases := []struct {
+		name    string
+		keyType string
+		records string
+		want    string
+		err     string
+	}{
+		{
+			name:    "string",
+			keyType: "string",
+			records: `[{"key": "a", "value": "x"}, {"key": "b c"}]`,
+			want:    "\"a\" := \"x\",\n\"b c\",\n",
+		},
+		{
+			name:    "integer without exponent",
+			keyType: "integer",
+			records: `[{"key": 1000000, "value": 2000000}, {"key": -1, "value": "neg"}, {"key": 12345678901}]`,
+			want:    "1000000 := \"2000000\",\n-1 := \"neg\",\n12345678901,\n",
+		},
+		{
+			name:    "ip network and host",
+			keyType: "ip",
+			records: `[{"key": "10.0.0.0/8", "value": "net"}, {"key": "10.1.1.1", "value": "host"}, {"key": "2001::/64"}]`,
+			want:    "network 10.0.0.0/8 := \"net\",\nhost 10.1.1.1 := \"host\",\nnetwork 2001::/64,\n",
+		},
+		{
+			name:    "no key",
+			keyType: "string",
+			records: `[{"value": "x"}]`,
+			err:     `data group record requires key: map[value:x]`,
+		},
+		{
+			name:    "unknown key type",
+			keyType: "float",
+			records: `[{"key": 1.5}]`,
+			err:     "unknown data group keyDataType: float",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var records interface{}
+			if err := json.Unmarshal([]byte(c.records), &records); err != nil {
+				t.Fatal(err)
+			}
+			got, err := dataGroupFileContent(c.keyType, ":=", records)
+			if c.err != "" {
+				if err == nil || err.Error() != c.err {
+					t.Errorf("got error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	cases := []struct {
+		in         string
+		addr, mask string
+		err        string
+	}{
+		{in: "10.1.0.1", addr: "10.1.0.1"},
+		{in: "10.1.0.0/16", addr: "10.1.0.0", mask: "255.255.0.0"},
+		{in: "10.1.0.0%2/16", addr: "10.1.0.0%2", mask: "255.255.0.0"},
+		{in: "0.0.0.0/0", addr: "0.0.0.0", mask: "0.0.0.0"},
+		{in: "2001::1%3", addr: "2001::1%3"},
+		{in: "2001::/64", addr: "2001::", mask: "ffff:ffff:ffff:ffff::"},
+		{in: "10.1.0.0/33", err: "invalid prefix length in address 10.1.0.0/33"},
+		{in: "2001::/129", err: "invalid prefix length in address 2001::/129"},
+		{in: "10.1.0.0/x", err: "invalid prefix length in address 10.1.0.0/x"},
+		{in: "10.1.0.1%65535", err: "invalid route domain in address 10.1.0.1%65535"},
+		{in: "10.1.0.1%a", err: "invalid route domain in address 10.1.0.1%a"},
+		{in: "www.example.com", err: "invalid address www.example.com"},
+	}
+	for _, c := range cases {
+		addr, mask, err := splitAddress(c.in)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v, want %s", c.in, err, c.err)
+			}
+			continue
+		}
+		if err != nil || addr != c.addr || mask != c.mask {
+			t.Errorf("%s: got %q, %q, %v, want %q, %q", c.in, addr, mask, err, c.addr, c.mask)
+		}
+	}
+}
+
+func TestMaskBits(t *testing.T) {
+	cases := []struct {
+		mask string
+		bits int
+	}{
+		{"255.255.255.255", 32},
+		{"255.255.0.0", 16},
+		{"0.0.0.0", 0},
+		{"ffff:ffff:ffff:ffff::", 64},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", 128},
+		{"::", 0},
+		// not contiguous.
+		{"255.0.255.0", -1},
+		{"any", -1},
+	}
+	for _, c := range cases {
+		if got := maskBits(c.mask); got != c.bits {
+			t.Errorf("%s: got %d, want %d", c.mask, got, c.bits)
+		}
+	}
+}
+
+func TestTenantRouteDomains(t *testing.T) {
+	declaration := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"class": "ADC",
+		"T1": {"class": "Tenant", "defaultRouteDomain": 2},
+		"T2": {"class": "Tenant"},
+		"T3": {"class": "Tenant", "defaultRouteDomain": 0},
+		"T4": {"class": "Tenant", "defaultRouteDomain": 65535},
+		"T5": {"class": "Tenant", "defaultRouteDomain": 1.5},
+		"T6": {"class": "Tenant", "defaultRouteDomain": "2"},
+		"Common": {"class": "Tenant", "defaultRouteDomain": 65534}
+	}`), &declaration); err != nil {
+		t.Fatal(err)
+	}
+	rds, err := tenantRouteDomains(declaration)
+	if want := map[string]int{"T1": 2, "T3": 0, "Common": 65534}; !reflect.DeepEqual(rds, want) {
+		t.Errorf("got %v, want %v", rds, want)
+	}
+	want := []string{
+		"/T4/defaultRouteDomain invalid-value",
+		"/T5/defaultRouteDomain invalid-value",
+		"/T6/defaultRouteDomain invalid-value",
+	}
+	if got := diagnostics(err); got != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestTenantAddress(t *testing.T) {
+	cc := &ConvertContext{routeDomains: map[string]int{"T": 2, "T0": 0}}
+	cases := []struct {
+		parent, addr string
+		want         string
+		err          string
+	}{
+		{parent: "/T/A", addr: "10.1.0.1", want: "10.1.0.1%2"},
+		{parent: "T", addr: "10.1.0.1", want: "10.1.0.1%2"},
+		{parent: "/T/A", addr: "2001::1", want: "2001::1%2"},
+		// the route domain in the address is kept.
+		{parent: "/T/A", addr: "10.1.0.1%3", want: "10.1.0.1%3"},
+		{parent: "/T/A", addr: "10.1.0.1%0", want: "10.1.0.1%0"},
+		// the default route domain 0 is omitted.
+		{parent: "/T0/A", addr: "10.1.0.1", want: "10.1.0.1"},
+		{parent: "/Other/A", addr: "10.1.0.1", want: "10.1.0.1"},
+		{parent: "/T/A", addr: "10.1.0.0/16", err: "prefix length is not allowed in address 10.1.0.0/16"},
+		{parent: "/T/A", addr: "10.1.0.1%65535", err: "invalid route domain in address 10.1.0.1%65535"},
+		{parent: "/T/A", addr: "host", err: "invalid address host"},
+	}
+	for _, c := range cases {
+		got, err := cc.tenantAddress(c.parent, c.addr)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s %s: got error %v, want %s", c.parent, c.addr, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s %s: got %q, %v, want %q", c.parent, c.addr, got, err, c.want)
+		}
+	}
+}
+
+func TestRdAddress(t *testing.T) {
+	cases := []struct {
+		addr string
+		rd   int
+		want string
+	}{
+		{"10.1.0.1", 2, "10.1.0.1%2"},
+		{"2001::1", 65534, "2001::1%65534"},
+		{"10.1.0.1", 0, "10.1.0.1"},
+		{"10.1.0.1%3", 2, "10.1.0.1%3"},
+		{"10.1.0.1%3", 0, "10.1.0.1%3"},
+	}
+	for _, c := range cases {
+		if got := rdAddress(c.addr, c.rd); got != c.want {
+			t.Errorf("%s %d: got %s, want %s", c.addr, c.rd, got, c.want)
+		}
+	}
+}
+
+func TestAddrRouteDomain(t *testing.T) {
+	cases := []struct {
+		addr string
+		rd   int
+	}{
+		{"10.1.0.1", 0},
+		{"10.1.0.1%2", 2},
+		{"192.168.0.0%3/16", 3},
+		{"2001::1%65534", 65534},
+		{"10.1.0.1%0", 0},
+	}
+	for _, c := range cases {
+		if got := addrRouteDomain(c.addr); got != c.rd {
+			t.Errorf("%s: got %d, want %d", c.addr, got, c.rd)
+		}
+	}
+}
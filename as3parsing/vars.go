@@ -2,15 +2,39 @@ package as3parsing
 
 import (
 	"embed"
+	"time"
+)
+
+const (
+	// DefaultsModeSchema fills the declaration with the embedded as3.defaults.json, no as3 service needed.
+	DefaultsModeSchema = "schema"
+	// DefaultsModeLocal posts the declaration to <as3Service>/validate with scratch 'defaults-only'.
+	DefaultsModeLocal = "local"
+	// DefaultsModeBigip posts the declaration to BIG-IP as a 'dry-run' with show=full.
+	DefaultsModeBigip = "bigip"
+)
 
-	f5_bigip "github.com/f5devcentral/f5-bigip-rest-go/bigip"
+const (
+	// ReadinessLazy checks the as3 service on the first ParseAS3 call.
+	ReadinessLazy = "lazy"
+	// ReadinessSkip never checks the as3 service.
+	ReadinessSkip = "skip"
+)
+
+// the polling of the as3 dry-run task, variables for the tests to shorten.
+var (
+	as3TaskPollInterval    = 1 * time.Second
+	as3TaskPollMaxInterval = 10 * time.Second
+	as3TaskTimeout         = 5 * time.Minute
 )
 
 var (
-	properties map[string]Properties
+	// DefaultRetryPolicy waits for the as3 service up to 10 minutes.
+	DefaultRetryPolicy = RetryPolicy{Times: 60, Interval: 10 * time.Second}
 	// slog       *utils.SLOG
-	as3Service string
-	bigip      *f5_bigip.BIGIP
+	defaultParser *Parser
 	//go:embed rest.properties.json
 	propFile embed.FS
+	//go:embed as3.defaults.json
+	defaultsFile embed.FS
 )
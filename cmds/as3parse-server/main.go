@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/zongzw/f5-as3-parsing/as3parsing"
+	"github.com/f5devcentral/f5-bigip-rest-go/utils"
+)
+
+// maxBodySize limits the size of the declarations posted.
+const maxBodySize = 32 << 20
+
+type server struct {
+	parser   *as3parsing.Parser
+	logLevel string
+	requests uint64
+}
+
+func main() {
+	var listen, version, logLevel string
+	var concurrency int
+
+	flag.StringVar(&listen, "listen", ":8080", "the address to listen on")
+	flag.StringVar(&version, "bigip-version", "", "the BIG-IP version to generate the REST objects for, i.e. 15.1.0")
+	flag.IntVar(&concurrency, "concurrency", runtime.NumCPU(), "how many as3 applications to convert at the same time, per request")
+	flag.StringVar(&logLevel, "log-level", utils.LogLevel_Type_INFO, "log level: trace, debug, info, warn or error")
+	flag.Parse()
+
+	slog := utils.NewLog().WithLevel(logLevel)
+	// defaults are added offline, so that the server can act as the as3 service of other parsers.
+	p, err := as3parsing.NewParser(
+		as3parsing.WithDefaultsMode(as3parsing.DefaultsModeSchema),
+		as3parsing.WithBIGIPVersion(version),
+		as3parsing.WithConcurrency(concurrency),
+	)
+	if err != nil {
+		slog.Errorf("failed to create parser: %s", err.Error())
+		os.Exit(1)
+	}
+	s := &server{parser: p, logLevel: logLevel}
+
+	slog.Infof("listening on %s", listen)
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		slog.Errorf("server quit: %s", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	// POST /parse: as3 body -> REST objects, or the diagnostics with 422
+	// POST /parse/<tenant>[,<tenant>...]: the same, of the tenants only
+	mux.HandleFunc("/parse", s.handle(s.parse))
+	mux.HandleFunc("/parse/", s.handle(s.parse))
+	// POST /validate: as3 body -> {"valid": true|false, "diagnostics": [...]}
+	mux.HandleFunc("/validate", s.handle(s.validate))
+	// the as3 service for DefaultsModeLocal, i.e. WithAS3Service("http://<listen>/as3")
+	mux.HandleFunc("/as3/any", s.handle(s.ready))
+	mux.HandleFunc("/as3/validate", s.handle(s.defaults))
+	return mux
+}
+
+// handle sets up the logger of the request, and writes the response returned by h as json, 500 if h panics.
+func (s *server) handle(h func(ctx context.Context, r *http.Request) (int, interface{})) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqid := fmt.Sprintf("%d", atomic.AddUint64(&s.requests, 1))
+		slog := utils.NewLog().WithLevel(s.logLevel).WithRequestID(reqid)
+		ctx := context.WithValue(r.Context(), utils.CtxKey_Logger, slog)
+		ctx = context.WithValue(ctx, utils.CtxKey_RequestID, reqid)
+
+		status, resp := func() (status int, resp interface{}) {
+			// a panic is answered with 500, instead of an empty response.
+			defer func() {
+				if rec := recover(); rec != nil {
+					slog.Errorf("panic on %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+					status, resp = http.StatusInternalServerError, errorResponse(fmt.Errorf("internal error: %v", rec))
+				}
+			}()
+			return h(ctx, r)
+		}()
+		body, err := json.Marshal(resp)
+		if err != nil {
+			status, body = http.StatusInternalServerError, []byte(fmt.Sprintf(`{"message": %q}`, err.Error()))
+		}
+		slog.Infof("%s %s %d", r.Method, r.URL.Path, status)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}
+}
+
+func (s *server) parse(ctx context.Context, r *http.Request) (int, interface{}) {
+	as3body, status, err := readBody(r)
+	if err != nil {
+		return status, errorResponse(err)
+	}
+	var restobjs map[string]interface{}
+	if tenants := strings.Trim(strings.TrimPrefix(r.URL.Path, "/parse"), "/"); tenants != "" {
+		restobjs, err = s.parser.ParseAS3Tenants(ctx, as3body, strings.Split(tenants, ",")...)
+	} else {
+		restobjs, err = s.parser.ParseAS3(ctx, as3body)
+	}
+	if err != nil {
+		var ds as3parsing.Diagnostics
+		if errors.As(err, &ds) {
+			return http.StatusUnprocessableEntity, map[string]interface{}{"diagnostics": ds}
+		}
+		return http.StatusBadRequest, errorResponse(err)
+	}
+	return http.StatusOK, restobjs
+}
+
+func (s *server) validate(ctx context.Context, r *http.Request) (int, interface{}) {
+	as3body, status, err := readBody(r)
+	if err != nil {
+		return status, errorResponse(err)
+	}
+	ds := as3parsing.Diagnostics{}
+	if _, err := s.parser.ParseAS3(ctx, as3body); err != nil && !errors.As(err, &ds) {
+		ds = as3parsing.Diagnostics{{Severity: as3parsing.SeverityError, Code: as3parsing.CodeInvalidValue, Message: err.Error()}}
+	}
+	return http.StatusOK, map[string]interface{}{"valid": !ds.HasErrors(), "diagnostics": ds}
+}
+
+func (s *server) ready(ctx context.Context, r *http.Request) (int, interface{}) {
+	return http.StatusOK, map[string]interface{}{}
+}
+
+// defaults returns the declaration with defaults, as the as3 service does for 'scratch: defaults-only'.
+func (s *server) defaults(ctx context.Context, r *http.Request) (int, interface{}) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, errorResponse(fmt.Errorf("method %s not allowed", r.Method))
+	}
+	var declaration map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&declaration); err != nil {
+		return http.StatusBadRequest, errorResponse(fmt.Errorf("failed to unmarshal the declaration: %s", err.Error()))
+	}
+	delete(declaration, "scratch")
+	fulldecl, err := s.parser.AddDefaults(ctx, declaration)
+	if err != nil {
+		return http.StatusUnprocessableEntity, errorResponse(err)
+	}
+	return http.StatusOK, fulldecl
+}
+
+// readBody reads the as3 body posted, a bare ADC declaration is accepted as well.
+func readBody(r *http.Request) (map[string]interface{}, int, error) {
+	if r.Method != http.MethodPost {
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	var as3body map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&as3body); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to unmarshal the as3 body: %s", err.Error())
+	}
+	if _, f := as3body["declaration"]; !f && as3body["class"] == "ADC" {
+		as3body = map[string]interface{}{"class": "AS3", "declaration": as3body}
+	}
+	return as3body, http.StatusOK, nil
+}
+
+func errorResponse(err error) map[string]interface{} {
+	return map[string]interface{}{"message": err.Error()}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitee.com/zongzw/f5-as3-parsing/as3parsing"
+	"github.com/f5devcentral/f5-bigip-rest-go/utils"
+)
+
+const declaration = `{"class": "ADC", "schemaVersion": "3.0.0",
+	"T1": {"class": "Tenant", "A": {"class": "Application", "web": {"class": "Pool"}}},
+	"T2": {"class": "Tenant", "B": {"class": "Application", "p": {"class": "Pool"}}}}`
+
+const broken = `{"class": "ADC", "schemaVersion": "3.0.0",
+	"T": {"class": "Tenant", "A": {"class": "Application", "p": {"class": "Pool", "minimumMonitors": "any"}}}}`
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	p, err := as3parsing.NewParser(as3parsing.WithDefaultsMode(as3parsing.DefaultsModeSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server{parser: p, logLevel: utils.LogLevel_Type_ERROR}
+}
+
+func TestServer(t *testing.T) {
+	cases := []struct {
+		name, method, path, body string
+		status                   int
+		// want is the expected response, compared as json if set.
+		want string
+		// check checks the decoded response if set.
+		check func(t *testing.T, resp map[string]interface{})
+	}{
+		{
+			name:   "parse",
+			method: http.MethodPost, path: "/parse", body: declaration,
+			status: http.StatusOK,
+			check: func(t *testing.T, resp map[string]interface{}) {
+				for _, path := range [][3]string{{"T1", "A", "ltm/pool/web"}, {"T2", "B", "ltm/pool/p"}} {
+					folder, _ := resp[path[0]].(map[string]interface{})[path[1]].(map[string]interface{})
+					if _, f := folder[path[2]]; !f {
+						t.Errorf("%v not found in %v", path, resp)
+					}
+				}
+			},
+		},
+		{
+			name:   "parse tenants",
+			method: http.MethodPost, path: "/parse/T2", body: `{"class": "AS3", "declaration": ` + declaration + `}`,
+			status: http.StatusOK,
+			check: func(t *testing.T, resp map[string]interface{}) {
+				if _, f := resp["T1"]; f || resp["T2"] == nil {
+					t.Errorf("got partitions %v, want T2 only", resp)
+				}
+			},
+		},
+		{
+			name:   "parse unknown tenant",
+			method: http.MethodPost, path: "/parse/T2,Other", body: declaration,
+			status: http.StatusBadRequest,
+			want:   `{"message": "tenant Other not found in the declaration"}`,
+		},
+		{
+			name:   "parse diagnostics",
+			method: http.MethodPost, path: "/parse", body: broken,
+			status: http.StatusUnprocessableEntity,
+			want: `{"diagnostics": [{"pointer": "/T/A/p/minimumMonitors", "severity": "error", "code": "invalid-value",
+				"message": "minimumMonitors should be a number or 'all': any"}]}`,
+		},
+		{
+			name:   "parse bad json",
+			method: http.MethodPost, path: "/parse", body: `{"class": "ADC",`,
+			status: http.StatusBadRequest,
+			want:   `{"message": "failed to unmarshal the as3 body: unexpected EOF"}`,
+		},
+		{
+			name:   "parse get",
+			method: http.MethodGet, path: "/parse",
+			status: http.StatusMethodNotAllowed,
+			want:   `{"message": "method GET not allowed"}`,
+		},
+		{
+			name:   "validate",
+			method: http.MethodPost, path: "/validate", body: declaration,
+			status: http.StatusOK,
+			want:   `{"valid": true, "diagnostics": []}`,
+		},
+		{
+			name:   "validate diagnostics",
+			method: http.MethodPost, path: "/validate", body: broken,
+			status: http.StatusOK,
+			want: `{"valid": false, "diagnostics": [{"pointer": "/T/A/p/minimumMonitors", "severity": "error", "code": "invalid-value",
+				"message": "minimumMonitors should be a number or 'all': any"}]}`,
+		},
+		{
+			name:   "validate bad json",
+			method: http.MethodPost, path: "/validate", body: `[]`,
+			status: http.StatusBadRequest,
+			want:   `{"message": "failed to unmarshal the as3 body: json: cannot unmarshal array into Go value of type map[string]interface {}"}`,
+		},
+		{
+			name:   "as3 service ready",
+			method: http.MethodGet, path: "/as3/any",
+			status: http.StatusOK,
+			want:   `{}`,
+		},
+		{
+			name:   "as3 service defaults",
+			method: http.MethodPost, path: "/as3/validate", body: `{"scratch": "defaults-only", ` + declaration[1:],
+			status: http.StatusOK,
+			check: func(t *testing.T, resp map[string]interface{}) {
+				if _, f := resp["scratch"]; f {
+					t.Errorf("scratch should be removed: %v", resp)
+				}
+				pool, _ := resp["T1"].(map[string]interface{})["A"].(map[string]interface{})["web"].(map[string]interface{})
+				if pool["loadBalancingMode"] != "round-robin" {
+					t.Errorf("defaults not added to the pool: %v", pool)
+				}
+			},
+		},
+	}
+
+	ts := httptest.NewServer(newServer(t).routes())
+	defer ts.Close()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(c.method, ts.URL+c.path, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != c.status {
+				t.Errorf("got status %d, want %d: %s", res.StatusCode, c.status, body)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("got Content-Type %s", ct)
+			}
+			resp := map[string]interface{}{}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("invalid response %s: %s", body, err)
+			}
+			if c.want != "" {
+				want := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(c.want), &want); err != nil {
+					t.Fatal(err)
+				}
+				bgot, _ := json.Marshal(resp)
+				bwant, _ := json.Marshal(want)
+				if string(bgot) != string(bwant) {
+					t.Errorf("got %s, want %s", bgot, bwant)
+				}
+			}
+			if c.check != nil {
+				c.check(t, resp)
+			}
+		})
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	s := newServer(t)
+	h := s.handle(func(ctx context.Context, r *http.Request) (int, interface{}) {
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/parse", strings.NewReader(declaration)))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if got, want := strings.TrimSpace(w.Body.String()), `{"message":"internal error: boom"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// the server keeps serving after the panic.
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/as3/any", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d after the panic, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// the server serves as the as3 service of a parser in DefaultsModeLocal.
+func TestServerAsAS3Service(t *testing.T) {
+	ts := httptest.NewServer(newServer(t).routes())
+	defer ts.Close()
+
+	p, err := as3parsing.NewParser(as3parsing.WithDefaultsMode(as3parsing.DefaultsModeLocal), as3parsing.WithAS3Service(ts.URL+"/as3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as3body := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{"class": "AS3", "declaration": `+declaration+`}`), &as3body); err != nil {
+		t.Fatal(err)
+	}
+	restobjs, err := p.ParseAS3(context.TODO(), as3body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, _ := restobjs["T1"].(map[string]interface{})["A"].(map[string]interface{})["ltm/pool/web"].(map[string]interface{})
+	if pool["loadBalancingMode"] != "round-robin" {
+		t.Errorf("got pool %v, want the defaults added", pool)
+	}
+}
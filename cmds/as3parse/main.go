@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gitee.com/zongzw/f5-as3-parsing/as3parsing"
+	f5_bigip "github.com/f5devcentral/f5-bigip-rest-go/bigip"
+	"github.com/f5devcentral/f5-bigip-rest-go/utils"
+)
+
+const (
+	layoutNested  = "nested"
+	layoutFlat    = "flat"
+	layoutOrdered = "ordered"
+	layoutTmsh    = "tmsh"
+	layoutConfig  = "tmsh-config"
+)
+
+func main() {
+	var in, out, mode, as3Svc, bigipURL, bigipUser, bigipPassword, version, tenants, layout, logLevel string
+	var concurrency int
+
+	flag.StringVar(&in, "in", "-", "the file containing the as3 declaration, '-' for stdin")
+	flag.StringVar(&out, "out", "-", "the file to write the REST objects to, '-' for stdout")
+	flag.StringVar(&mode, "defaults-mode", as3parsing.DefaultsModeSchema, "where to add the as3 defaults from: \n  "+
+		"schema -> the embedded as3 schema, offline\n  "+
+		"local  -> the as3 service given by -as3-service\n  "+
+		"bigip  -> dry-run on the BIG-IP given by -bigip-url")
+	flag.StringVar(&as3Svc, "as3-service", "", "the as3 service url, i.e. http://localhost:8080, for defaults mode local")
+	flag.StringVar(&bigipURL, "bigip-url", "", "the BIG-IP url, i.e. https://10.1.1.245, for defaults mode bigip")
+	flag.StringVar(&bigipUser, "bigip-user", "admin", "the BIG-IP username")
+	flag.StringVar(&bigipPassword, "bigip-password", "", "the BIG-IP password, or env BIGIP_PASSWORD")
+	flag.StringVar(&version, "bigip-version", "", "the BIG-IP version to generate the REST objects for, i.e. 15.1.0")
+	flag.StringVar(&tenants, "tenants", "", "comma separated tenants to parse, all by default")
+	flag.StringVar(&layout, "layout", layoutNested, "the output layout: \n  "+
+		"nested  -> partition -> folder -> \"kind/name\" -> body, as ParseAS3 returns\n  "+
+		"flat    -> list of resources sorted by partition, folder and key\n  "+
+		"ordered -> list of resources in the order of creating them\n  "+
+		"tmsh    -> tmsh create commands in the order of creating them\n  "+
+		"tmsh-config -> tmsh config for 'tmsh load sys config merge'")
+	flag.IntVar(&concurrency, "concurrency", 1, "how many as3 applications to convert at the same time")
+	flag.StringVar(&logLevel, "log-level", utils.LogLevel_Type_ERROR, "log level: trace, debug, info, warn or error, logs of info and lower go to stdout")
+	flag.Parse()
+
+	slog := utils.NewLog().WithLevel(logLevel)
+	ctx := context.WithValue(context.TODO(), utils.CtxKey_Logger, slog)
+
+	if err := run(ctx, in, out, mode, as3Svc, bigipURL, bigipUser, bigipPassword, version, tenants, layout, concurrency); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, in, out, mode, as3Svc, bigipURL, bigipUser, bigipPassword, version, tenants, layout string, concurrency int) error {
+	switch layout {
+	case layoutNested, layoutFlat, layoutOrdered, layoutTmsh, layoutConfig:
+	default:
+		return fmt.Errorf("unknown layout: %s", layout)
+	}
+
+	as3body, err := readDeclaration(in)
+	if err != nil {
+		return err
+	}
+
+	opts := []as3parsing.ParserOption{
+		as3parsing.WithDefaultsMode(mode),
+		as3parsing.WithAS3Service(as3Svc),
+		as3parsing.WithBIGIPVersion(version),
+		as3parsing.WithConcurrency(concurrency),
+	}
+	if mode == as3parsing.DefaultsModeBigip {
+		if bigipPassword == "" {
+			bigipPassword = os.Getenv("BIGIP_PASSWORD")
+		}
+		bip, err := newBIGIP(bigipURL, bigipUser, bigipPassword)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, as3parsing.WithBIGIP(bip))
+	}
+	p, err := as3parsing.NewParser(opts...)
+	if err != nil {
+		return err
+	}
+
+	var restobjs map[string]interface{}
+	if tenants != "" {
+		names := []string{}
+		for _, t := range strings.Split(tenants, ",") {
+			names = append(names, strings.TrimSpace(t))
+		}
+		restobjs, err = p.ParseAS3Tenants(ctx, as3body, names...)
+	} else {
+		restobjs, err = p.ParseAS3(ctx, as3body)
+	}
+	if err != nil {
+		var ds as3parsing.Diagnostics
+		if errors.As(err, &ds) {
+			msgs := []string{}
+			for _, d := range ds {
+				msgs = append(msgs, fmt.Sprintf("%s %s %s: %s", d.Severity, d.Code, d.Pointer, d.Message))
+			}
+			return fmt.Errorf("failed to parse the declaration:\n%s", strings.Join(msgs, "\n"))
+		}
+		return fmt.Errorf("failed to parse the declaration: %s", err.Error())
+	}
+
+	if layout == layoutTmsh || layout == layoutConfig {
+		format := as3parsing.TmshCommands
+		if layout == layoutConfig {
+			format = as3parsing.TmshConfig
+		}
+		script, err := p.RenderTmsh(restobjs, format)
+		if err != nil {
+			return err
+		}
+		return write(out, []byte(script))
+	}
+
+	var output interface{}
+	switch layout {
+	case layoutNested:
+		output = restobjs
+	case layoutFlat:
+		result, err := as3parsing.NewResult(restobjs)
+		if err != nil {
+			return err
+		}
+		output = result.Resources("")
+	case layoutOrdered:
+		result, err := as3parsing.NewResult(restobjs)
+		if err != nil {
+			return err
+		}
+		resources := map[as3parsing.ResourceID]*as3parsing.Resource{}
+		for _, res := range result.Resources("") {
+			resources[res.ID()] = res
+		}
+		order, err := as3parsing.DependencyGraph(restobjs).CreateOrder()
+		if err != nil {
+			return err
+		}
+		ordered := []*as3parsing.Resource{}
+		for _, id := range order {
+			ordered = append(ordered, resources[id])
+		}
+		output = ordered
+	}
+
+	bout, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal the REST objects: %s", err.Error())
+	}
+	return write(out, append(bout, '\n'))
+}
+
+func write(out string, data []byte) error {
+	if out == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(out, data, 0644)
+}
+
+func readDeclaration(in string) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if in == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(in)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the declaration: %s", err.Error())
+	}
+	var as3body map[string]interface{}
+	if err := json.Unmarshal(data, &as3body); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the declaration: %s", err.Error())
+	}
+	// a bare ADC declaration is accepted as well.
+	if _, f := as3body["declaration"]; !f && as3body["class"] == "ADC" {
+		as3body = map[string]interface{}{"class": "AS3", "declaration": as3body}
+	}
+	return as3body, nil
+}
+
+// newBIGIP connects to the BIG-IP, f5_bigip.New panics if it's not available.
+func newBIGIP(url, user, password string) (bip *f5_bigip.BIGIP, err error) {
+	if url == "" {
+		return nil, fmt.Errorf("-bigip-url is required for defaults mode %s", as3parsing.DefaultsModeBigip)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return f5_bigip.New(url, user, password), nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitee.com/zongzw/f5-as3-parsing/as3parsing"
+)
+
+const declaration = `{"class": "AS3", "declaration": {"class": "ADC", "schemaVersion": "3.0.0",
+	"T1": {"class": "Tenant", "A": {"class": "Application",
+		"vs": {"class": "Service_HTTP", "virtualAddresses": ["10.0.0.1"], "pool": "web"},
+		"web": {"class": "Pool", "members": [{"servicePort": 80, "serverAddresses": ["10.1.0.1"]}]}}},
+	"T2": {"class": "Tenant", "B": {"class": "Application",
+		"p": {"class": "Pool"}}}}}`
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name    string
+		decl    string
+		tenants string
+		layout  string
+		// check checks the output.
+		check func(t *testing.T, out []byte)
+		err   string
+	}{
+		{
+			name:   "nested",
+			decl:   declaration,
+			layout: layoutNested,
+			check: func(t *testing.T, out []byte) {
+				restobjs := map[string]map[string]map[string]interface{}{}
+				if err := json.Unmarshal(out, &restobjs); err != nil {
+					t.Fatal(err)
+				}
+				for _, k := range []string{"ltm/virtual/vs", "ltm/pool/web"} {
+					if _, f := restobjs["T1"]["A"][k]; !f {
+						t.Errorf("%s not found in %v", k, restobjs["T1"]["A"])
+					}
+				}
+				if _, f := restobjs["T2"]["B"]["ltm/pool/p"]; !f {
+					t.Errorf("ltm/pool/p not found in %v", restobjs["T2"])
+				}
+			},
+		},
+		{
+			name:    "tenants",
+			decl:    declaration,
+			tenants: " T2",
+			layout:  layoutNested,
+			check: func(t *testing.T, out []byte) {
+				restobjs := map[string]interface{}{}
+				if err := json.Unmarshal(out, &restobjs); err != nil {
+					t.Fatal(err)
+				}
+				if _, f := restobjs["T1"]; f || restobjs["T2"] == nil {
+					t.Errorf("got partitions %v, want T2 only", restobjs)
+				}
+			},
+		},
+		{
+			name:   "bare adc",
+			decl:   `{"class": "ADC", "schemaVersion": "3.0.0", "T": {"class": "Tenant", "A": {"class": "Application", "p": {"class": "Pool"}}}}`,
+			layout: layoutFlat,
+			check: func(t *testing.T, out []byte) {
+				resources := []as3parsing.Resource{}
+				if err := json.Unmarshal(out, &resources); err != nil {
+					t.Fatal(err)
+				}
+				if len(resources) != 1 || resources[0].ID().String() != "/T/A/ltm/pool/p" {
+					t.Errorf("got %v, want /T/A/ltm/pool/p", resources)
+				}
+			},
+		},
+		{
+			name:   "ordered",
+			decl:   declaration,
+			layout: layoutOrdered,
+			check: func(t *testing.T, out []byte) {
+				resources := []as3parsing.Resource{}
+				if err := json.Unmarshal(out, &resources); err != nil {
+					t.Fatal(err)
+				}
+				index := map[string]int{}
+				for i, r := range resources {
+					index[r.ID().String()] = i
+				}
+				for _, order := range [][2]string{
+					{"/T1/ltm/node/10.1.0.1", "/T1/A/ltm/pool/web"},
+					{"/T1/A/ltm/pool/web", "/T1/A/ltm/virtual/vs"},
+					{"/T1/ltm/virtual-address/10.0.0.1", "/T1/A/ltm/virtual/vs"},
+				} {
+					before, f1 := index[order[0]]
+					after, f2 := index[order[1]]
+					if !f1 || !f2 || before > after {
+						t.Errorf("%s should be before %s in %v", order[0], order[1], index)
+					}
+				}
+			},
+		},
+		{
+			name:   "tmsh",
+			decl:   declaration,
+			layout: layoutTmsh,
+			check: func(t *testing.T, out []byte) {
+				script := string(out)
+				pool := strings.Index(script, "create ltm pool /T1/A/web ")
+				virtual := strings.Index(script, "create ltm virtual /T1/A/vs ")
+				if pool < 0 || virtual < pool {
+					t.Errorf("the pool should be created before the virtual:\n%s", script)
+				}
+			},
+		},
+		{
+			name:   "tmsh config",
+			decl:   declaration,
+			layout: layoutConfig,
+			check: func(t *testing.T, out []byte) {
+				if !strings.Contains(string(out), "\nltm pool /T1/A/web {\n") {
+					t.Errorf("ltm pool /T1/A/web not found:\n%s", out)
+				}
+			},
+		},
+		{
+			name:   "diagnostics",
+			decl:   `{"class": "ADC", "schemaVersion": "3.0.0", "T": {"class": "Tenant", "A": {"class": "Application", "p": {"class": "Pool", "minimumMonitors": "any"}}}}`,
+			layout: layoutNested,
+			err:    "failed to parse the declaration:\nerror invalid-value /T/A/p/minimumMonitors: ",
+		},
+		{
+			name:    "unknown tenant",
+			decl:    declaration,
+			tenants: "T2,Other",
+			layout:  layoutNested,
+			err:     "failed to parse the declaration: tenant Other not found in the declaration",
+		},
+		{
+			name:   "bad json",
+			decl:   `{"class": "ADC",`,
+			layout: layoutNested,
+			err:    "failed to unmarshal the declaration: unexpected end of JSON input",
+		},
+		{
+			name:   "unknown layout",
+			decl:   declaration,
+			layout: "yaml",
+			err:    "unknown layout: yaml",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			in, out := filepath.Join(dir, "declaration.json"), filepath.Join(dir, "out")
+			if err := os.WriteFile(in, []byte(c.decl), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := run(context.TODO(), in, out, as3parsing.DefaultsModeSchema, "", "", "admin", "", "", c.tenants, c.layout, 2)
+			if c.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+					t.Errorf("got error %v, want %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.check(t, data)
+		})
+	}
+}
+
+func TestRunInputErrors(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "declaration.json")
+	if err := os.WriteFile(in, []byte(declaration), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, in, mode string
+		err            string
+	}{
+		{name: "no file", in: filepath.Join(dir, "none.json"), mode: as3parsing.DefaultsModeSchema, err: "failed to read the declaration: "},
+		{name: "bigip without url", in: in, mode: as3parsing.DefaultsModeBigip, err: "-bigip-url is required for defaults mode bigip"},
+	}
+	for _, c := range cases {
+		err := run(context.TODO(), c.in, filepath.Join(dir, "out"), c.mode, "", "", "admin", "", "", "", layoutNested, 1)
+		if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want %s", c.name, err, c.err)
+		}
+	}
+}